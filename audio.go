@@ -0,0 +1,86 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <obs.h>
+#include <media-io/audio-io.h>
+*/
+import "C"
+
+// audioMixerTracks decodes a source's audio mixer bitmask into whether it's routed to each of OBS's audio tracks.
+func audioMixerTracks(mixers uint32) []bool {
+	tracks := make([]bool, C.MAX_AUDIO_MIXES)
+	for n := range tracks {
+		tracks[n] = mixers&(1<<n) != 0
+	}
+	return tracks
+}
+
+// audioMonitoringDevice returns the device OBS sends monitored audio to.
+// The strings are owned by libobs, so we copy them rather than freeing them.
+func audioMonitoringDevice() (name, id string) {
+	var nameC, idC *C.char
+	C.obs_get_audio_monitoring_device(&nameC, &idC)
+	return C.GoString(nameC), C.GoString(idC)
+}
+
+// obsAudioInfo is libobs's struct obs_audio_info.
+type obsAudioInfo = C.struct_obs_audio_info
+
+// audioInfo returns the sample rate and number of speaker channels OBS mixes audio at.
+// It returns false if audio hasn't been set up yet.
+func audioInfo() (sampleRate, speakers int, ok bool) {
+	var oai obsAudioInfo
+	if !C.obs_get_audio_info(&oai) {
+		return 0, 0, false
+	}
+	sampleRate, speakers = audioInfoValues(oai)
+	return sampleRate, speakers, true
+}
+
+// audioInfoValues returns the sample rate and number of speaker channels in oai, as OBS logs them.
+func audioInfoValues(oai obsAudioInfo) (sampleRate, speakers int) {
+	return int(oai.samples_per_sec), int(C.get_audio_channels(oai.speakers))
+}
+
+// globalAudioChannels maps the UUID of each source assigned to an output channel, such as the
+// desktop audio and mic/aux devices in OBS's audio settings, to its channel index.
+func globalAudioChannels() map[string]int {
+	slots := make([]string, C.MAX_CHANNELS)
+	for i := range slots {
+		src := C.obs_get_output_source(C.uint32_t(i))
+		if src == nil {
+			continue
+		}
+		slots[i] = C.GoString(C.obs_source_get_uuid(src))
+		C.obs_source_release(src)
+	}
+	return globalChannelsByUUID(slots)
+}
+
+// globalChannelsByUUID maps the UUID of the source in each output channel slot to the slot's
+// index. Empty slots are skipped, and a source in more than one slot gets the lowest.
+func globalChannelsByUUID(slots []string) map[string]int {
+	channels := map[string]int{}
+	for i, uuid := range slots {
+		if _, ok := channels[uuid]; uuid == "" || ok {
+			continue
+		}
+		channels[uuid] = i
+	}
+	return channels
+}
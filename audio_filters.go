@@ -0,0 +1,84 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <obs.h>
+
+void mc_enum_filters_cb(obs_source_t*, obs_source_t*, void*);
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+// audioFilterParams are the settings we export for each of OBS's built-in audio filters, by unversioned filter ID.
+var audioFilterParams = map[string][]string{
+	"gain_filter":       {"db"},
+	"compressor_filter": {"ratio", "threshold"},
+	"expander_filter":   {"ratio", "threshold"},
+	"limiter_filter":    {"threshold"},
+	"noise_gate_filter": {"open_threshold", "close_threshold"},
+}
+
+type audioFilterSnapshot struct {
+	ID     string
+	Name   string
+	Params map[string]float64
+}
+
+// audioFilterParamsFromData reads the settings we export for a filter, or nil if it isn't one we know.
+func audioFilterParamsFromData(filterID string, data settingsData) map[string]float64 {
+	keys, ok := audioFilterParams[filterID]
+	if !ok {
+		return nil
+	}
+	params := make(map[string]float64, len(keys))
+	for _, key := range keys {
+		params[key] = data.Double(key)
+	}
+	return params
+}
+
+// snapshotAudioFilters returns the known audio filters on a source.
+func (c *MetricCollector) snapshotAudioFilters(o *C.obs_source_t) []audioFilterSnapshot {
+	var filters []audioFilterSnapshot
+	c.enumFiltersCB = func(parent, child *C.obs_source_t, v unsafe.Pointer) {
+		id := C.GoString(C.obs_source_get_unversioned_id(child))
+		if _, ok := audioFilterParams[id]; !ok {
+			return
+		}
+		data := C.obs_source_get_settings(child)
+		if data == nil {
+			return
+		}
+		defer C.obs_data_release(data)
+		filters = append(filters, audioFilterSnapshot{
+			ID:     id,
+			Name:   C.GoString(C.obs_source_get_name(child)),
+			Params: audioFilterParamsFromData(id, obsData{data}),
+		})
+	}
+	C.obs_source_enum_filters(o, C.obs_source_enum_proc_t(C.mc_enum_filters_cb), nil)
+	return filters
+}
+
+//export mc_enum_filters_cb_go
+func mc_enum_filters_cb_go(parent, child *C.obs_source_t, f unsafe.Pointer) {
+	defer recoverCollectPanic(nil)
+	activeMetricCollector.enumFiltersCB(parent, child, f)
+}
@@ -0,0 +1,37 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAudioFilterParams(t *testing.T) {
+	for _, tc := range []struct {
+		id       string
+		settings fakeSettings
+		want     map[string]float64
+	}{
+		{"gain_filter", fakeSettings{"db": 6.5}, map[string]float64{"db": 6.5}},
+		{"noise_gate_filter", fakeSettings{"open_threshold": -26.0, "close_threshold": -32.0, "attack_time": 25},
+			map[string]float64{"open_threshold": -26, "close_threshold": -32}},
+		{"vst_filter", fakeSettings{"db": 6.5}, nil},
+	} {
+		if got := audioFilterParamsFromData(tc.id, tc.settings); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("audioFilterParamsFromData(%q, %v) = %v, want %v", tc.id, tc.settings, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAudioMixerTracks(t *testing.T) {
+	for _, tc := range []struct {
+		mixers uint32
+		want   []bool
+	}{
+		{0, []bool{false, false, false, false, false, false}},
+		{0b000001, []bool{true, false, false, false, false, false}},
+		{0b100101, []bool{true, false, true, false, false, true}},
+		{0b111111, []bool{true, true, true, true, true, true}},
+		// Bits above the six tracks OBS has are ignored.
+		{0b1000010, []bool{false, true, false, false, false, false}},
+	} {
+		if got := audioMixerTracks(tc.mixers); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("audioMixerTracks(%#b) = %v, want %v", tc.mixers, got, tc.want)
+		}
+	}
+}
+
+func TestGlobalChannelsByUUID(t *testing.T) {
+	// Desktop audio in slot 1, and a mic in the first mic/aux slot, 3.
+	slots := []string{"", "desktop", "", "mic", "", ""}
+	got := globalChannelsByUUID(slots)
+	want := map[string]int{"desktop": 1, "mic": 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("globalChannelsByUUID(%q) = %v, want %v", slots, got, want)
+	}
+	if _, ok := got["camera"]; ok {
+		t.Error("a source in no slot was given a global channel")
+	}
+
+	slots = []string{"", "", "", "mic", "mic", ""}
+	if got := globalChannelsByUUID(slots)["mic"]; got != 3 {
+		t.Errorf("source in slots 3 and 4 was given channel %d, want 3", got)
+	}
+}
+
+func TestAudioInfoValues(t *testing.T) {
+	for _, tc := range []struct {
+		oai                  obsAudioInfo
+		sampleRate, speakers int
+	}{
+		// SPEAKERS_STEREO.
+		{obsAudioInfo{samples_per_sec: 48000, speakers: 2}, 48000, 2},
+		// SPEAKERS_5POINT1.
+		{obsAudioInfo{samples_per_sec: 44100, speakers: 6}, 44100, 6},
+		// SPEAKERS_7POINT1.
+		{obsAudioInfo{samples_per_sec: 48000, speakers: 8}, 48000, 8},
+	} {
+		if sampleRate, speakers := audioInfoValues(tc.oai); sampleRate != tc.sampleRate || speakers != tc.speakers {
+			t.Errorf("audioInfoValues(%+v) = %d, %d, want %d, %d", tc.oai, sampleRate, speakers, tc.sampleRate, tc.speakers)
+		}
+	}
+}
+
+func TestEmitPushToTalk(t *testing.T) {
+	snap := &collectorSnapshot{Up: true, Sources: []sourceSnapshot{
+		{ID: "wasapi_input_capture", Name: "Mic", IsAudio: true, PushToTalk: true},
+		{ID: "wasapi_output_capture", Name: "Desktop Audio", IsAudio: true, PushToMute: true},
+		// Push-to-talk only applies to audio sources.
+		{ID: "dshow_input", Name: "Camera", IsVideo: true},
+	}}
+	ms := emitSnapshot(t, newTestCollector(t), snap)
+
+	for _, tc := range []struct {
+		source   string
+		ptt, ptm float64
+	}{
+		{"Mic", 1, 0},
+		{"Desktop Audio", 0, 1},
+	} {
+		labels := map[string]string{"source_name": tc.source}
+		if m, ok := findMetric(ms, "obs_source_push_to_talk_enabled", labels); !ok || m.Value != tc.ptt {
+			t.Errorf("%s: obs_source_push_to_talk_enabled = %v (emitted %v), want %v", tc.source, m.Value, ok, tc.ptt)
+		}
+		if m, ok := findMetric(ms, "obs_source_push_to_mute_enabled", labels); !ok || m.Value != tc.ptm {
+			t.Errorf("%s: obs_source_push_to_mute_enabled = %v (emitted %v), want %v", tc.source, m.Value, ok, tc.ptm)
+		}
+	}
+	if _, ok := findMetric(ms, "obs_source_push_to_talk_enabled", map[string]string{"source_name": "Camera"}); ok {
+		t.Error("obs_source_push_to_talk_enabled emitted for a video source")
+	}
+}
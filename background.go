@@ -0,0 +1,120 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	backgroundMu     sync.Mutex
+	backgroundCtx    context.Context
+	backgroundCancel context.CancelFunc
+	backgroundWG     sync.WaitGroup
+)
+
+// staleHeartbeats is how many of its intervals a goroutine can go without a heartbeat before it's unhealthy.
+const staleHeartbeats = 3
+
+type backgroundNameKey struct{}
+
+type heartbeatState struct {
+	last     time.Time
+	interval time.Duration
+}
+
+var (
+	heartbeatsMu sync.Mutex
+	heartbeats   = map[string]heartbeatState{}
+)
+
+// heartbeat records that the background goroutine running with ctx is still making progress,
+// and that it expects to call heartbeat again within interval.
+func heartbeat(ctx context.Context, interval time.Duration) {
+	name, ok := ctx.Value(backgroundNameKey{}).(string)
+	if !ok {
+		return
+	}
+	heartbeatsMu.Lock()
+	defer heartbeatsMu.Unlock()
+	heartbeats[name] = heartbeatState{last: time.Now(), interval: interval}
+}
+
+// healthy reports whether the heartbeat is recent enough as of now.
+func (h heartbeatState) healthy(now time.Time) bool {
+	return now.Sub(h.last) <= staleHeartbeats*h.interval
+}
+
+// goroutineHealth exports whether each background goroutine that sends heartbeats is healthy.
+type goroutineHealth struct {
+	desc *prometheus.Desc
+}
+
+var backgroundHealth = &goroutineHealth{
+	desc: prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporterSubsystem, "goroutine_healthy"),
+		"Whether this background goroutine has sent a heartbeat recently.",
+		[]string{"name"}, prometheus.Labels{},
+	),
+}
+
+func (g *goroutineHealth) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+func (g *goroutineHealth) Collect(ch chan<- prometheus.Metric) {
+	heartbeatsMu.Lock()
+	defer heartbeatsMu.Unlock()
+	now := time.Now()
+	for name, h := range heartbeats {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, boolMetric(h.healthy(now)), name)
+	}
+}
+
+// startBackground runs fn in a new goroutine. The context passed to fn is cancelled by stopBackground.
+func startBackground(name string, fn func(ctx context.Context)) {
+	backgroundMu.Lock()
+	defer backgroundMu.Unlock()
+	if backgroundCtx == nil {
+		backgroundCtx, backgroundCancel = context.WithCancel(context.Background())
+	}
+	ctx := context.WithValue(backgroundCtx, backgroundNameKey{}, name)
+	backgroundWG.Add(1)
+	go func() {
+		defer backgroundWG.Done()
+		slog.Debug("background goroutine started", "name", name)
+		fn(ctx)
+		heartbeatsMu.Lock()
+		delete(heartbeats, name)
+		heartbeatsMu.Unlock()
+		slog.Debug("background goroutine stopped", "name", name)
+	}()
+}
+
+// stopBackground cancels every goroutine started by startBackground and waits for them to exit.
+func stopBackground() {
+	backgroundMu.Lock()
+	if backgroundCancel != nil {
+		backgroundCancel()
+	}
+	backgroundCtx, backgroundCancel = nil, nil
+	backgroundMu.Unlock()
+	backgroundWG.Wait()
+}
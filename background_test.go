@@ -0,0 +1,64 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHeartbeatHealthy(t *testing.T) {
+	now := time.Now()
+	for _, tc := range []struct {
+		age  time.Duration
+		want bool
+	}{
+		{0, true},
+		{25 * time.Second, true},
+		{30 * time.Second, true},
+		{31 * time.Second, false},
+	} {
+		h := heartbeatState{last: now.Add(-tc.age), interval: 10 * time.Second}
+		if got := h.healthy(now); got != tc.want {
+			t.Errorf("healthy with a heartbeat %v ago every 10s = %v, want %v", tc.age, got, tc.want)
+		}
+	}
+}
+
+func TestStaleHeartbeatIsUnhealthy(t *testing.T) {
+	heartbeatsMu.Lock()
+	heartbeats["test_fresh"] = heartbeatState{last: time.Now(), interval: time.Second}
+	heartbeats["test_stale"] = heartbeatState{last: time.Now().Add(-time.Minute), interval: time.Second}
+	heartbeatsMu.Unlock()
+	defer func() {
+		heartbeatsMu.Lock()
+		delete(heartbeats, "test_fresh")
+		delete(heartbeats, "test_stale")
+		heartbeatsMu.Unlock()
+	}()
+
+	want := `
+# HELP obs_exporter_goroutine_healthy Whether this background goroutine has sent a heartbeat recently.
+# TYPE obs_exporter_goroutine_healthy gauge
+obs_exporter_goroutine_healthy{name="test_fresh"} 1
+obs_exporter_goroutine_healthy{name="test_stale"} 0
+`
+	if err := testutil.CollectAndCompare(backgroundHealth, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -21,6 +21,7 @@ package main
 #cgo windows LDFLAGS: -L. -lobs
 #include <obs-module.h>
 #include <obs.h>
+#include <obs-frontend-api.h>
 
 bool mc_enum_sources_cb(void* f, obs_output_t* s) {
 	bool mc_enum_sources_cb_go(void*, obs_output_t*);
@@ -34,9 +35,50 @@ bool mc_enum_encoders_cb(void* f, obs_encoder_t* s) {
 	bool mc_enum_encoders_cb_go(void*, obs_encoder_t*);
 	return mc_enum_encoders_cb_go(f, s);
 }
+bool mc_enum_scenes_cb(void* f, obs_source_t* s) {
+	bool mc_enum_scenes_cb_go(void*, obs_source_t*);
+	return mc_enum_scenes_cb_go(f, s);
+}
+bool mc_enum_scene_items_cb(obs_scene_t* scene, obs_sceneitem_t* item, void* f) {
+	bool mc_enum_scene_items_cb_go(obs_scene_t*, obs_sceneitem_t*, void*);
+	return mc_enum_scene_items_cb_go(scene, item, f);
+}
+void mc_enum_filters_cb(obs_source_t* parent, obs_source_t* child, void* f) {
+	void mc_enum_filters_cb_go(obs_source_t*, obs_source_t*, void*);
+	mc_enum_filters_cb_go(parent, child, f);
+}
 void mc_volmeter_updated(void* f, const float magnitude[MAX_AUDIO_CHANNELS], const float peak[MAX_AUDIO_CHANNELS], const float input_peak[MAX_AUDIO_CHANNELS]) {
 	void mc_volmeter_updated_go(void*, const float[MAX_AUDIO_CHANNELS], const float[MAX_AUDIO_CHANNELS], const float[MAX_AUDIO_CHANNELS]);
 	mc_volmeter_updated_go(f, magnitude, peak, input_peak);
 }
+void mc_source_volume_cb(void* f, calldata_t* cd) {
+	void mc_source_volume_cb_go(void*);
+	mc_source_volume_cb_go(f);
+}
+void mc_output_stop_cb(void* f, calldata_t* cd) {
+	void mc_output_stop_cb_go(void*, long long);
+	mc_output_stop_cb_go(f, calldata_int(cd, "code"));
+}
+void mc_output_event_cb_go(void*, int, long long);
+void mc_output_start_cb(void* f, calldata_t* cd) {
+	mc_output_event_cb_go(f, 0, 0);
+}
+void mc_output_stop_event_cb(void* f, calldata_t* cd) {
+	mc_output_event_cb_go(f, 1, 0);
+}
+void mc_output_reconnect_cb(void* f, calldata_t* cd) {
+	mc_output_event_cb_go(f, 2, calldata_int(cd, "timeout_sec"));
+}
+void mc_output_reconnect_success_cb(void* f, calldata_t* cd) {
+	mc_output_event_cb_go(f, 3, 0);
+}
+void mc_debug_dump_hotkey_cb(void* f, obs_hotkey_id id, obs_hotkey_t* hotkey, bool pressed) {
+	void mc_debug_dump_hotkey_cb_go(bool);
+	mc_debug_dump_hotkey_cb_go(pressed);
+}
+void mc_frontend_event_cb(enum obs_frontend_event event, void *data) {
+	void mc_frontend_event_cb_go(int, void*);
+	mc_frontend_event_cb_go((int)event, data);
+}
 */
 import "C"
@@ -0,0 +1,57 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// captureTargetKeys maps capture source type IDs to the settings keys that hold what they're capturing, most preferred first.
+var captureTargetKeys = map[string][]string{
+	// Windows
+	"monitor_capture": {"monitor_id", "monitor"},
+	"window_capture":  {"window"},
+	"game_capture":    {"window"},
+	// Linux
+	"xshm_input":       {"screen"},
+	"xcomposite_input": {"capture_window"},
+	// macOS
+	"display_capture": {"display_uuid", "display"},
+	"screen_capture":  {"display_uuid", "application", "window"},
+}
+
+// captureTarget extracts the capture target from a source's settings, if it's a capture source with a target set.
+func captureTarget(sourceID, settingsJSON string) (string, bool) {
+	keys, ok := captureTargetKeys[sourceID]
+	if !ok {
+		return "", false
+	}
+	var settings map[string]interface{}
+	if err := json.Unmarshal([]byte(settingsJSON), &settings); err != nil {
+		return "", false
+	}
+	for _, key := range keys {
+		switch v := settings[key].(type) {
+		case string:
+			if v != "" {
+				return v, true
+			}
+		case float64:
+			return fmt.Sprintf("%v", v), true
+		}
+	}
+	return "", false
+}
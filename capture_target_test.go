@@ -0,0 +1,45 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestCaptureTarget(t *testing.T) {
+	for _, tc := range []struct {
+		sourceID string
+		settings string
+		want     string
+		wantOK   bool
+	}{
+		{"window_capture", `{"window": "Untitled - Notepad:Notepad:notepad.exe"}`, "Untitled - Notepad:Notepad:notepad.exe", true},
+		{"game_capture", `{"capture_mode": "window", "window": "Game:UnityWndClass:game.exe"}`, "Game:UnityWndClass:game.exe", true},
+		{"monitor_capture", `{"monitor_id": "\\\\?\\DISPLAY#1", "monitor": 0}`, `\\?\DISPLAY#1`, true},
+		// Older OBS versions only store the monitor's index.
+		{"monitor_capture", `{"monitor": 1}`, "1", true},
+		{"xshm_input", `{"screen": 0}`, "0", true},
+		{"xcomposite_input", `{"capture_window": "0x4000007\r\nFirefox\r\nfirefox"}`, "0x4000007\r\nFirefox\r\nfirefox", true},
+		{"display_capture", `{"display_uuid": "37D8832A-2D66-02CA-B9F7-8F30A301B230", "display": 1}`, "37D8832A-2D66-02CA-B9F7-8F30A301B230", true},
+		{"screen_capture", `{"application": "com.apple.Safari"}`, "com.apple.Safari", true},
+		{"window_capture", `{"window": ""}`, "", false},
+		{"window_capture", `{}`, "", false},
+		{"window_capture", `not json`, "", false},
+		{"image_source", `{"file": "/tmp/logo.png"}`, "", false},
+	} {
+		got, ok := captureTarget(tc.sourceID, tc.settings)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("captureTarget(%q, %q) = %q, %v, want %q, %v", tc.sourceID, tc.settings, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Environment variables read by loadConfig.
+const (
+	envPort           = "OBS_EXPORTER_PORT"
+	envPushgatewayURL = "OBS_EXPORTER_PUSHGATEWAY_URL"
+	envPushInterval   = "OBS_EXPORTER_PUSH_INTERVAL"
+	envOTLPEndpoint   = "OBS_EXPORTER_OTLP_ENDPOINT"
+	envOTLPInterval   = "OBS_EXPORTER_OTLP_INTERVAL"
+	envInfluxURL      = "OBS_EXPORTER_INFLUX_URL"
+	envInfluxBucket   = "OBS_EXPORTER_INFLUX_BUCKET"
+	envInfluxOrg      = "OBS_EXPORTER_INFLUX_ORG"
+	envInfluxToken    = "OBS_EXPORTER_INFLUX_TOKEN"
+	envInfluxInterval = "OBS_EXPORTER_INFLUX_INTERVAL"
+	envFilePath       = "OBS_EXPORTER_FILE_PATH"
+	envFileInterval   = "OBS_EXPORTER_FILE_INTERVAL"
+	envFileMaxBytes   = "OBS_EXPORTER_FILE_MAX_BYTES"
+
+	envTLSCertFile     = "OBS_EXPORTER_TLS_CERT_FILE"
+	envTLSKeyFile      = "OBS_EXPORTER_TLS_KEY_FILE"
+	envTLSClientCAFile = "OBS_EXPORTER_TLS_CLIENT_CA_FILE"
+
+	envShutdownTimeout = "OBS_EXPORTER_SHUTDOWN_TIMEOUT"
+	envListeners       = "OBS_EXPORTER_LISTENERS"
+
+	envLogTailLines    = "OBS_EXPORTER_LOGTAIL_LINES"
+	envLogTailUsername = "OBS_EXPORTER_LOGTAIL_USERNAME"
+	envLogTailPassword = "OBS_EXPORTER_LOGTAIL_PASSWORD"
+
+	envSourceNameTemplate = "OBS_EXPORTER_SOURCE_NAME_TEMPLATE"
+	envCaptureTargets     = "OBS_EXPORTER_CAPTURE_TARGETS"
+	envAudioTracks        = "OBS_EXPORTER_AUDIO_TRACKS"
+	envSampleTimestamps   = "OBS_EXPORTER_SAMPLE_TIMESTAMPS"
+	envEncoderCPU         = "OBS_EXPORTER_ENCODER_CPU"
+	envCombineChannels    = "OBS_EXPORTER_COMBINE_CHANNELS"
+	envAudioFilters       = "OBS_EXPORTER_AUDIO_FILTERS"
+	envMaxSources         = "OBS_EXPORTER_MAX_SOURCES"
+	envHelpOverrides      = "OBS_EXPORTER_HELP_OVERRIDES"
+	envMetrics            = "OBS_EXPORTER_METRICS"
+	envGroups             = "OBS_EXPORTER_GROUPS"
+	envSceneReferences    = "OBS_EXPORTER_SCENE_REFERENCES"
+	envProfileEncoders    = "OBS_EXPORTER_PROFILE_ENCODERS"
+	envPeakHistogram      = "OBS_EXPORTER_PEAK_HISTOGRAM"
+	envPeakBuckets        = "OBS_EXPORTER_PEAK_HISTOGRAM_BUCKETS"
+	envPeakHoldMS         = "OBS_EXPORTER_PEAK_HOLD_MS"
+	envPeakDecayMS        = "OBS_EXPORTER_PEAK_DECAY_MS"
+	envHealthWeights      = "OBS_EXPORTER_HEALTH_WEIGHTS"
+)
+
+var activeConfig = defaultConfig()
+
+type Config struct {
+	// Port to listen for HTTP on. 0 lets the OS pick a free port; -1 scans upwards from 9407.
+	Port int
+
+	// TLSCertFile and TLSKeyFile, if set, enable serving HTTPS instead of HTTP.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile, if set, requires clients to present a certificate signed by one of these CAs.
+	TLSClientCAFile string
+	// Listeners, if set, replaces Port and the TLS settings with a list of listeners to serve on.
+	Listeners []ListenerConfig
+	// LogTailUsername and LogTailPassword, if both set, enable /logtail, protected by HTTP basic
+	// authentication, which serves the last LogTailLines lines logged by the exporter.
+	LogTailUsername string
+	LogTailPassword string
+	LogTailLines    int
+	// ShutdownTimeout is how long to wait for in-flight requests when OBS exits.
+	ShutdownTimeout time.Duration
+
+	// PushgatewayURL, if set, enables periodically pushing metrics to a Pushgateway.
+	PushgatewayURL string
+	PushInterval   time.Duration
+
+	// OTLPEndpoint, if set, enables periodically exporting metrics to an OTLP/HTTP collector.
+	OTLPEndpoint string
+	OTLPInterval time.Duration
+	// InfluxURL and InfluxBucket, if set, enable periodically writing metrics to InfluxDB.
+	InfluxURL      string
+	InfluxBucket   string
+	InfluxOrg      string
+	InfluxToken    string
+	InfluxInterval time.Duration
+
+	// FilePath, if set, enables periodically appending metrics to a file.
+	FilePath     string
+	FileInterval time.Duration
+	// FileMaxBytes is the size after which the file is rotated.
+	FileMaxBytes int
+
+	// MaxSources, if positive, limits the number of sources exported.
+	MaxSources int
+	// SourceNameTemplate, if set, is used to render the source_name label.
+	SourceNameTemplate *template.Template
+	// CaptureTargets enables exporting what each capture source is capturing.
+	CaptureTargets bool
+	// AudioTracks enables exporting a series per source per audio track, in addition to the mixer bitmask.
+	AudioTracks bool
+	// SampleTimestamps enables attaching the time they were sampled to windowed audio levels.
+	SampleTimestamps bool
+	// EncoderCPU enables estimating the CPU usage of software video encoders.
+	EncoderCPU bool
+	// CombineChannels enables exporting one series per source for audio levels, rather than one per channel.
+	CombineChannels bool
+	// AudioFilters enables exporting the settings of built-in audio filters.
+	AudioFilters bool
+	// ProfileEncoders enables reading every profile's streaming encoder from disk.
+	ProfileEncoders bool
+	// PeakHold is how long obs_source_channel_peak_hold holds a peak before falling, and
+	// PeakDecay how long it takes to fall by 20 dB.
+	PeakHold  time.Duration
+	PeakDecay time.Duration
+	// PeakHistogram enables observing every volmeter update's peak into a histogram per source.
+	PeakHistogram        bool
+	PeakHistogramBuckets []float64
+	// Groups enables exporting which scene sources are groups and how many items they hold.
+	Groups bool
+	// SceneReferences enables exporting how many scenes each source is in.
+	SceneReferences bool
+	// EnabledMetrics turns individual metrics on or off, keyed by metric name. Metrics that
+	// aren't listed are left as they are.
+	EnabledMetrics map[string]bool
+	// HelpOverrides replaces the help text of metrics, keyed by metric name.
+	HelpOverrides map[string]string
+	// HealthWeights is how much each component counts towards the stream health score.
+	HealthWeights healthWeights
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Port:            -1,
+		ShutdownTimeout: 5 * time.Second,
+		LogTailLines:    200,
+		PushInterval:    15 * time.Second,
+		OTLPInterval:    15 * time.Second,
+		InfluxInterval:  15 * time.Second,
+		FileInterval:    time.Minute,
+		FileMaxBytes:    10 << 20,
+
+		PeakHold:             20 * time.Second,
+		PeakDecay:            1700 * time.Millisecond,
+		PeakHistogramBuckets: defaultPeakBuckets,
+		HealthWeights:        defaultHealthWeights,
+	}
+}
+
+func loadConfig() *Config {
+	fileSettings = loadSettingsFile()
+	return configFromSettings()
+}
+
+// configFromSettings builds a config from the environment and fileSettings.
+func configFromSettings() *Config {
+	cfg := defaultConfig()
+	cfg.Port = envInt(envPort, cfg.Port)
+	cfg.TLSCertFile = setting(envTLSCertFile)
+	cfg.TLSKeyFile = setting(envTLSKeyFile)
+	cfg.TLSClientCAFile = setting(envTLSClientCAFile)
+	cfg.ShutdownTimeout = envDuration(envShutdownTimeout, cfg.ShutdownTimeout)
+	if v := setting(envListeners); v != "" {
+		listeners, err := parseListeners(v)
+		if err != nil {
+			countError(errorConfigParse)
+			slog.Warn("invalid listeners, using the default listener", "name", envListeners, "value", v, "err", err)
+		} else {
+			cfg.Listeners = listeners
+		}
+	}
+	cfg.LogTailUsername = setting(envLogTailUsername)
+	cfg.LogTailPassword = setting(envLogTailPassword)
+	cfg.LogTailLines = envInt(envLogTailLines, cfg.LogTailLines)
+	cfg.PushgatewayURL = setting(envPushgatewayURL)
+	cfg.PushInterval = envDuration(envPushInterval, cfg.PushInterval)
+	cfg.OTLPEndpoint = setting(envOTLPEndpoint)
+	cfg.OTLPInterval = envDuration(envOTLPInterval, cfg.OTLPInterval)
+	cfg.InfluxURL = setting(envInfluxURL)
+	cfg.InfluxBucket = setting(envInfluxBucket)
+	cfg.InfluxOrg = setting(envInfluxOrg)
+	cfg.InfluxToken = setting(envInfluxToken)
+	cfg.InfluxInterval = envDuration(envInfluxInterval, cfg.InfluxInterval)
+	cfg.FilePath = setting(envFilePath)
+	cfg.FileInterval = envDuration(envFileInterval, cfg.FileInterval)
+	cfg.FileMaxBytes = envInt(envFileMaxBytes, cfg.FileMaxBytes)
+	cfg.CaptureTargets = envBool(envCaptureTargets, cfg.CaptureTargets)
+	cfg.AudioTracks = envBool(envAudioTracks, cfg.AudioTracks)
+	cfg.SampleTimestamps = envBool(envSampleTimestamps, cfg.SampleTimestamps)
+	cfg.EncoderCPU = envBool(envEncoderCPU, cfg.EncoderCPU)
+	cfg.CombineChannels = envBool(envCombineChannels, cfg.CombineChannels)
+	cfg.AudioFilters = envBool(envAudioFilters, cfg.AudioFilters)
+	cfg.MaxSources = envInt(envMaxSources, cfg.MaxSources)
+	cfg.Groups = envBool(envGroups, cfg.Groups)
+	cfg.SceneReferences = envBool(envSceneReferences, cfg.SceneReferences)
+	cfg.ProfileEncoders = envBool(envProfileEncoders, cfg.ProfileEncoders)
+	cfg.PeakHold = envMillis(envPeakHoldMS, cfg.PeakHold, 0, maxPeakHoldMS)
+	cfg.PeakDecay = envMillis(envPeakDecayMS, cfg.PeakDecay, minPeakDecayMS, maxPeakDecayMS)
+	cfg.PeakHistogram = envBool(envPeakHistogram, cfg.PeakHistogram)
+	if v := setting(envPeakBuckets); v != "" {
+		buckets, err := parseBuckets(v)
+		if err != nil {
+			countError(errorConfigParse)
+			slog.Warn("invalid histogram buckets, using default", "name", envPeakBuckets, "value", v, "default", cfg.PeakHistogramBuckets, "err", err)
+		} else {
+			cfg.PeakHistogramBuckets = buckets
+		}
+	}
+	if v := setting(envMetrics); v != "" {
+		if err := json.Unmarshal([]byte(v), &cfg.EnabledMetrics); err != nil {
+			countError(errorConfigParse)
+			slog.Warn("invalid metric map, exporting every metric", "name", envMetrics, "value", v, "err", err)
+			cfg.EnabledMetrics = nil
+		}
+	}
+	if v := setting(envHelpOverrides); v != "" {
+		if err := json.Unmarshal([]byte(v), &cfg.HelpOverrides); err != nil {
+			countError(errorConfigParse)
+			slog.Warn("invalid help overrides, using the default help text", "name", envHelpOverrides, "value", v, "err", err)
+			cfg.HelpOverrides = nil
+		}
+	}
+	if v := setting(envHealthWeights); v != "" {
+		weights, err := parseHealthWeights(v)
+		if err != nil {
+			countError(errorConfigParse)
+			slog.Warn("invalid health score weights, using default", "name", envHealthWeights, "value", v, "err", err)
+		} else {
+			cfg.HealthWeights = weights
+		}
+	}
+	if v := setting(envSourceNameTemplate); v != "" {
+		tmpl, err := compileSourceNameTemplate(v)
+		if err != nil {
+			countError(errorConfigParse)
+			slog.Warn("invalid source name template, using raw source names", "name", envSourceNameTemplate, "value", v, "err", err)
+		} else {
+			cfg.SourceNameTemplate = tmpl
+		}
+	}
+	return cfg
+}
+
+// fileSettings holds the values from the settings file, keyed by settingsKey.
+var fileSettings map[string]string
+
+// settingsKey returns the key in the settings file for an environment variable,
+// e.g. port for OBS_EXPORTER_PORT.
+func settingsKey(env string) string {
+	return strings.ToLower(strings.TrimPrefix(env, "OBS_EXPORTER_"))
+}
+
+// setting returns the value of an environment variable, or of the matching key in the settings file if it isn't set.
+func setting(name string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fileSettings[settingsKey(name)]
+}
+
+// parseSettings reads a settings file's JSON object into strings, as they would be given in
+// environment variables. Strings are used as they are, and numbers, booleans, objects and
+// arrays as their JSON, so settings like OBS_EXPORTER_LISTENERS can be written out in full.
+func parseSettings(s string) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, err
+	}
+	settings := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if string(v) == "null" {
+			continue
+		}
+		var str string
+		if err := json.Unmarshal(v, &str); err == nil {
+			settings[k] = str
+			continue
+		}
+		settings[k] = string(v)
+	}
+	return settings, nil
+}
+
+// configMu guards activeConfig, which reloadConfig can replace while metrics are being collected.
+// obs_module_load reads activeConfig without it, since nothing can reload the config until it's done.
+var configMu sync.RWMutex
+
+// currentConfig returns the active config, for code that can run while it's being reloaded.
+// A config is never changed once it's applied, so it can be used after the lock is released.
+func currentConfig() *Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return activeConfig
+}
+
+// applyConfig makes cfg the active config.
+func applyConfig(cfg *Config) {
+	configMu.Lock()
+	activeConfig = cfg
+	configMu.Unlock()
+	configReloads.Inc()
+	configLastReload.SetToCurrentTime()
+}
+
+// reloadConfig reads the settings again and applies those that are used on each scrape. The rest,
+// like the listeners and exporters, are only used when the exporter is loaded, so they keep
+// their current values until OBS is restarted.
+func reloadConfig() {
+	cfg := loadConfig()
+	next := *currentConfig()
+	next.CaptureTargets = cfg.CaptureTargets
+	next.AudioTracks = cfg.AudioTracks
+	next.SampleTimestamps = cfg.SampleTimestamps
+	next.EncoderCPU = cfg.EncoderCPU
+	next.AudioFilters = cfg.AudioFilters
+	next.MaxSources = cfg.MaxSources
+	next.Groups = cfg.Groups
+	next.SceneReferences = cfg.SceneReferences
+	next.ProfileEncoders = cfg.ProfileEncoders
+	next.PeakHold = cfg.PeakHold
+	next.PeakDecay = cfg.PeakDecay
+	next.EnabledMetrics = cfg.EnabledMetrics
+	next.HealthWeights = cfg.HealthWeights
+	next.SourceNameTemplate = cfg.SourceNameTemplate
+	applyConfig(&next)
+}
+
+func envInt(name string, def int) int {
+	v := setting(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		countError(errorConfigParse)
+		slog.Warn("invalid integer, using default", "name", name, "value", v, "default", def, "err", err)
+		return def
+	}
+	return n
+}
+
+// envMillis reads a duration given as a whole number of milliseconds between min and max.
+func envMillis(name string, def time.Duration, min, max int) time.Duration {
+	v := setting(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err == nil && (n < min || n > max) {
+		err = fmt.Errorf("must be between %d and %d", min, max)
+	}
+	if err != nil {
+		countError(errorConfigParse)
+		slog.Warn("invalid milliseconds, using default", "name", name, "value", v, "default", def, "err", err)
+		return def
+	}
+	return time.Duration(n) * time.Millisecond
+}
+
+func envBool(name string, def bool) bool {
+	v := setting(name)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		countError(errorConfigParse)
+		slog.Warn("invalid boolean, using default", "name", name, "value", v, "default", def, "err", err)
+		return def
+	}
+	return b
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	v := setting(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		countError(errorConfigParse)
+		slog.Warn("invalid duration, using default", "name", name, "value", v, "default", def, "err", err)
+		return def
+	}
+	return d
+}
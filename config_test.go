@@ -0,0 +1,166 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestApplyConfigCountsReloads(t *testing.T) {
+	defer applyConfig(activeConfig)
+	reloads := testutil.ToFloat64(configReloads)
+	configLastReload.Set(0)
+
+	before := time.Now()
+	applyConfig(defaultConfig())
+
+	if got := testutil.ToFloat64(configReloads) - reloads; got != 1 {
+		t.Errorf("config_reloads_total went up by %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(configLastReload); got < float64(before.Unix()) {
+		t.Errorf("config_last_reload_timestamp_seconds = %v, want at least %v", got, before.Unix())
+	}
+}
+
+func TestReloadConfigKeepsLoadTimeSettings(t *testing.T) {
+	defer applyConfig(activeConfig)
+	applyConfig(defaultConfig())
+	t.Setenv(envPort, "9999")
+	t.Setenv(envGroups, "true")
+
+	reloadConfig()
+
+	cfg := currentConfig()
+	if cfg.Port != defaultConfig().Port {
+		t.Errorf("Port = %d after reloading, want it left at %d until restart", cfg.Port, defaultConfig().Port)
+	}
+	if !cfg.Groups {
+		t.Error("Groups = false after reloading, want true")
+	}
+}
+
+func TestParseSettings(t *testing.T) {
+	got, err := parseSettings(`{
+		"port": 9407,
+		"push_interval": "30s",
+		"groups": true,
+		"listeners": [{"address": "127.0.0.1", "port": 9407}],
+		"metrics": {"obs_source_input_peak": false},
+		"pushgateway_url": null
+	}`)
+	if err != nil {
+		t.Fatalf("parseSettings: %v", err)
+	}
+	want := map[string]string{
+		"port":          "9407",
+		"push_interval": "30s",
+		"groups":        "true",
+		"listeners":     `[{"address": "127.0.0.1", "port": 9407}]`,
+		"metrics":       `{"obs_source_input_peak": false}`,
+	}
+	if len(got) != len(want) {
+		t.Errorf("parseSettings returned %d settings, want %d: %v", len(got), len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("setting %q = %q, want %q", k, got[k], v)
+		}
+	}
+
+	if _, err := parseSettings(`["not", "an", "object"]`); err == nil {
+		t.Error("parseSettings of a JSON array succeeded, want an error")
+	}
+}
+
+func TestSettingPrecedence(t *testing.T) {
+	defer func(old map[string]string) { fileSettings = old }(fileSettings)
+	fileSettings = map[string]string{"port": "9410", "groups": "true"}
+	t.Setenv(envPort, "9420")
+	t.Setenv(envGroups, "")
+
+	if got := settingsKey(envPort); got != "port" {
+		t.Errorf("settingsKey(%q) = %q, want port", envPort, got)
+	}
+	if got := setting(envPort); got != "9420" {
+		t.Errorf("setting(%q) = %q, want the environment's 9420", envPort, got)
+	}
+	if got := setting(envGroups); got != "true" {
+		t.Errorf("setting(%q) = %q, want the file's true", envGroups, got)
+	}
+	if got := setting(envPushgatewayURL); got != "" {
+		t.Errorf("setting(%q) = %q, want it unset", envPushgatewayURL, got)
+	}
+}
+
+func TestConfigFromSettingsFile(t *testing.T) {
+	defer func(old map[string]string) { fileSettings = old }(fileSettings)
+	stored := map[string]interface{}{
+		"port":              9410,
+		"listeners":         []ListenerConfig{{Address: "127.0.0.1", Port: 9411, Username: "prom", Password: "secret"}},
+		"logtail_username":  "admin",
+		"push_interval":     "1m",
+		"groups":            true,
+		"max_sources":       50,
+		"peak_hold_ms":      2500,
+		"metrics":           map[string]bool{"obs_source_input_peak": false},
+		"health_weights":    map[string]float64{"congestion": 1},
+		"influx_url":        "http://localhost:8086",
+		"combine_channels":  false,
+		"sample_timestamps": true,
+	}
+	for k := range stored {
+		t.Setenv("OBS_EXPORTER_"+strings.ToUpper(k), "")
+	}
+	// The whole settings file goes through the same JSON OBS writes it as.
+	data, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileSettings, err = parseSettings(string(data))
+	if err != nil {
+		t.Fatalf("parseSettings: %v", err)
+	}
+
+	cfg := configFromSettings()
+	want := defaultConfig()
+	want.Port = 9410
+	want.Listeners = []ListenerConfig{{Address: "127.0.0.1", Port: 9411, Username: "prom", Password: "secret"}}
+	want.LogTailUsername = "admin"
+	want.PushInterval = time.Minute
+	want.Groups = true
+	want.MaxSources = 50
+	want.PeakHold = 2500 * time.Millisecond
+	want.EnabledMetrics = map[string]bool{"obs_source_input_peak": false}
+	want.HealthWeights = defaultHealthWeights
+	want.HealthWeights.Congestion = 1
+	want.InfluxURL = "http://localhost:8086"
+	want.CombineChannels = false
+	want.SampleTimestamps = true
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("config from the settings file = %+v, want %+v", cfg, want)
+	}
+
+	// The environment still takes precedence over the file.
+	t.Setenv("OBS_EXPORTER_PORT", "9420")
+	if cfg := configFromSettings(); cfg.Port != 9420 {
+		t.Errorf("Port = %d with OBS_EXPORTER_PORT set, want 9420", cfg.Port)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <obs.h>
+
+void mc_debug_dump_hotkey_cb(void*, obs_hotkey_id, obs_hotkey_t*, bool);
+*/
+import "C"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	debugDumpHotkeyName        = C.CString("obs_studio_exporter.dump_metrics")
+	debugDumpHotkeyDescription = C.CString("Log all exported metrics")
+
+	debugDumpHotkey = C.obs_hotkey_id(C.OBS_INVALID_HOTKEY_ID)
+
+	// dumpRequested is set when the hotkey is pressed, and cleared once the metrics have been logged,
+	// so holding the hotkey or pressing it again mid-dump doesn't log them twice.
+	dumpRequested atomic.Bool
+)
+
+// dumpMetrics gathers every metric family from g and logs each sample at debug level,
+// so they end up in the OBS log file for bug reports.
+func dumpMetrics(g prometheus.Gatherer) {
+	mfs, err := g.Gather()
+	if err != nil {
+		slog.Warn("gathering metrics to dump failed", "err", err)
+		if len(mfs) == 0 {
+			return
+		}
+	}
+	var buf bytes.Buffer
+	if err := writeSnapshot(&buf, mfs, time.Now()); err != nil {
+		slog.Warn("formatting metrics to dump failed", "err", err)
+		return
+	}
+	slog.Debug(fmt.Sprintf("dumping %d metric families", len(mfs)))
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		slog.Debug(scanner.Text())
+	}
+}
+
+// requestMetricsDump logs all metrics, unless a dump is already under way.
+func requestMetricsDump() {
+	if !dumpRequested.CompareAndSwap(false, true) {
+		return
+	}
+	// Gathering scrapes OBS, which can't happen on the hotkey thread.
+	go func() {
+		defer dumpRequested.Store(false)
+		dumpMetrics(exportedMetrics)
+	}()
+}
+
+func registerDebugDumpHotkey() {
+	debugDumpHotkey = C.obs_hotkey_register_frontend(debugDumpHotkeyName, debugDumpHotkeyDescription, C.obs_hotkey_func(C.mc_debug_dump_hotkey_cb), nil)
+}
+
+func unregisterDebugDumpHotkey() {
+	if debugDumpHotkey == C.OBS_INVALID_HOTKEY_ID {
+		return
+	}
+	C.obs_hotkey_unregister(debugDumpHotkey)
+	debugDumpHotkey = C.OBS_INVALID_HOTKEY_ID
+}
+
+//export mc_debug_dump_hotkey_cb_go
+func mc_debug_dump_hotkey_cb_go(pressed C.bool) {
+	if pressed {
+		requestMetricsDump()
+	}
+}
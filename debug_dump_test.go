@@ -0,0 +1,54 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestDumpMetricsLogsEveryFamily(t *testing.T) {
+	const families = 3
+	reg := prometheus.NewRegistry()
+	for i := 0; i < families; i++ {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Name: fmt.Sprintf("test_gauge_%d", i), Help: "A test gauge."})
+		g.Set(float64(i))
+		reg.MustRegister(g)
+	}
+
+	logs := recordLogs(t)
+	dumpMetrics(reg)
+
+	msgs := logs.Messages()
+	if len(msgs) == 0 || msgs[0] != fmt.Sprintf("dumping %d metric families", families) {
+		t.Fatalf("first logged message = %q, want the family count", msgs)
+	}
+	for i := 0; i < families; i++ {
+		name := fmt.Sprintf("test_gauge_%d", i)
+		found := false
+		for _, m := range msgs[1:] {
+			if strings.HasPrefix(m, name+" ") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("no sample for %s was logged", name)
+		}
+	}
+}
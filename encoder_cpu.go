@@ -0,0 +1,58 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+)
+
+// libobs doesn't tell us which threads belong to which encoder, so this is an estimate:
+// software video encoders run on libobs' video output thread, and the worker threads they
+// start inherit its name, so we sample the CPU time of all threads with that name. Only
+// software encoders are counted; hardware encoders barely use the CPU.
+var softwareVideoEncoders = map[string]bool{
+	"obs_x264":       true,
+	"ffmpeg_aom_av1": true,
+	"ffmpeg_svt_av1": true,
+}
+
+// encoderCPUSampler is what we remember between scrapes to work out encoding CPU usage.
+type encoderCPUSampler struct {
+	lastCPU    time.Duration
+	lastSample time.Time
+}
+
+// cpuPercent returns the CPU used between two samples as a percentage of one core.
+func cpuPercent(prevCPU, curCPU time.Duration, elapsed time.Duration) (float64, bool) {
+	if elapsed <= 0 || curCPU < prevCPU {
+		return 0, false
+	}
+	return 100 * float64(curCPU-prevCPU) / float64(elapsed), true
+}
+
+// sample returns the encoding threads' CPU usage since the last sample.
+// It returns false on the first sample, or if per-thread CPU time isn't available here.
+func (s *encoderCPUSampler) sample(now time.Time) (float64, bool) {
+	cpu, ok := encodingThreadsCPUTime()
+	if !ok {
+		return 0, false
+	}
+	prevCPU, prevSample := s.lastCPU, s.lastSample
+	s.lastCPU, s.lastSample = cpu, now
+	if prevSample.IsZero() {
+		return 0, false
+	}
+	return cpuPercent(prevCPU, cpu, now.Sub(prevSample))
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// #include <unistd.h>
+import "C"
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// encodingThreadPrefix is the start of the name of libobs' video output thread, truncated as Linux does.
+const encodingThreadPrefix = "video-io"
+
+var clockTicks = time.Duration(C.sysconf(C._SC_CLK_TCK))
+
+// encodingThreadsCPUTime sums the user and system CPU time of the threads in this process that do video encoding.
+func encodingThreadsCPUTime() (time.Duration, bool) {
+	tasks, err := filepath.Glob("/proc/self/task/*/stat")
+	if err != nil || len(tasks) == 0 || clockTicks <= 0 {
+		return 0, false
+	}
+	var total time.Duration
+	for _, task := range tasks {
+		stat, err := os.ReadFile(task)
+		if err != nil {
+			// The thread exited.
+			continue
+		}
+		name, ticks, ok := parseTaskStat(string(stat))
+		if !ok || !strings.HasPrefix(name, encodingThreadPrefix) {
+			continue
+		}
+		total += time.Duration(ticks) * time.Second / clockTicks
+	}
+	return total, true
+}
+
+// parseTaskStat returns the name and utime+stime, in clock ticks, from /proc/<pid>/task/<tid>/stat.
+func parseTaskStat(stat string) (name string, ticks uint64, ok bool) {
+	// The name is in parentheses and may itself contain spaces or parentheses.
+	lparen, rparen := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if lparen < 0 || rparen < lparen {
+		return "", 0, false
+	}
+	name = stat[lparen+1 : rparen]
+	// Fields after the name start at field 3 (state); utime and stime are fields 14 and 15.
+	fields := strings.Fields(stat[rparen+1:])
+	if len(fields) < 13 {
+		return "", 0, false
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return name, utime + stime, true
+}
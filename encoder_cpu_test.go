@@ -0,0 +1,41 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCPUPercent(t *testing.T) {
+	for _, tc := range []struct {
+		prev, cur, elapsed time.Duration
+		want               float64
+		ok                 bool
+	}{
+		{0, 500 * time.Millisecond, time.Second, 50, true},
+		// Several encoding threads can use more than one core between them.
+		{time.Second, 4 * time.Second, 2 * time.Second, 150, true},
+		{time.Second, time.Second, time.Second, 0, true},
+		{0, time.Second, 0, 0, false},
+		// The thread CPU times going backwards means the threads we were sampling went away.
+		{2 * time.Second, time.Second, time.Second, 0, false},
+	} {
+		got, ok := cpuPercent(tc.prev, tc.cur, tc.elapsed)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("cpuPercent(%v, %v, %v) = %v, %v; want %v, %v", tc.prev, tc.cur, tc.elapsed, got, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+)
+
+// uniqueEncoderNames renames encoders that share an ID and name with an earlier one, so that
+// every encoder's series have different labels. Otherwise the whole scrape fails, which happens
+// when several audio tracks are encoded by encoders with the same name. Audio encoders are
+// told apart by their track first, and anything still clashing is numbered.
+func uniqueEncoderNames(snaps []encoderSnapshot) {
+	type key struct{ id, name string }
+	seen := map[key]bool{}
+	for i := range snaps {
+		e := &snaps[i]
+		if !seen[key{e.ID, e.Name}] {
+			seen[key{e.ID, e.Name}] = true
+			continue
+		}
+		base := e.Name
+		if e.IsAudio && e.Track > 0 {
+			base = fmt.Sprintf("%s (track %d)", e.Name, e.Track)
+		}
+		name := base
+		for n := 2; seen[key{e.ID, name}]; n++ {
+			name = fmt.Sprintf("%s #%d", base, n)
+		}
+		e.Name = name
+		seen[key{e.ID, name}] = true
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <obs.h>
+*/
+import "C"
+
+// encoderSettings holds the settings we export for an encoder.
+type encoderSettings struct {
+	Preset string
+	// Bitrate is the configured bitrate in kbps, if the encoder has one.
+	Bitrate int
+	// GPU is the index of the GPU the encoder runs on, or -1 if the encoder doesn't pick one.
+	GPU int
+}
+
+// Newer NVENC versions keep their preset in preset2 (p1-p7), leaving the legacy preset key behind.
+var encoderPresetKeys = []string{"preset2", "preset"}
+
+var encoderBitrateKey = "bitrate"
+
+// nvenc and qsv both keep the GPU index under gpu.
+var encoderGPUKey = "gpu"
+
+func encoderSettingsFromData(data settingsData) encoderSettings {
+	return encoderSettings{
+		Preset:  data.String(encoderPresetKeys...),
+		Bitrate: data.Int(encoderBitrateKey),
+		GPU:     data.IntDefault(encoderGPUKey, -1),
+	}
+}
+
+func getEncoderSettings(e *C.obs_encoder_t) encoderSettings {
+	data := C.obs_encoder_get_settings(e)
+	if data == nil {
+		return encoderSettings{GPU: -1}
+	}
+	defer C.obs_data_release(data)
+	return encoderSettingsFromData(obsData{data})
+}
+
+// encoderCapDynBitrate is the capability flag for encoders which can change their bitrate while running.
+const encoderCapDynBitrate = C.OBS_ENCODER_CAP_DYN_BITRATE
+
+// encoderReconfigurable reports whether an encoder's capability flags say it can be reconfigured
+// while it's running. Dynamic bitrate is the only reconfiguration encoders advertise; encoders
+// which predate capability flags report none, so they're treated as not reconfigurable.
+func encoderReconfigurable(caps uint32) bool {
+	return caps&encoderCapDynBitrate != 0
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestEncoderPreset(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		settings fakeSettings
+		want     string
+	}{
+		{"x264", fakeSettings{"preset": "veryfast", "bitrate": 6000}, "veryfast"},
+		{"nvenc", fakeSettings{"preset2": "p5", "preset": "hq"}, "p5"},
+		{"legacy nvenc", fakeSettings{"preset2": "", "preset": "hq"}, "hq"},
+		{"no preset", fakeSettings{"bitrate": 6000}, ""},
+	} {
+		if got := encoderSettingsFromData(tc.settings).Preset; got != tc.want {
+			t.Errorf("%s: Preset = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestEncoderGPU(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		settings fakeSettings
+		want     int
+	}{
+		{"nvenc on the second GPU", fakeSettings{"gpu": 1, "preset2": "p5"}, 1},
+		{"nvenc on the first GPU", fakeSettings{"gpu": 0}, 0},
+		{"x264", fakeSettings{"preset": "veryfast"}, -1},
+	} {
+		if got := encoderSettingsFromData(tc.settings).GPU; got != tc.want {
+			t.Errorf("%s: GPU = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestEncoderReconfigurable(t *testing.T) {
+	const passTexture = 1 << 1
+	for _, tc := range []struct {
+		caps uint32
+		want bool
+	}{
+		{0, false},
+		{passTexture, false},
+		{encoderCapDynBitrate, true},
+		{encoderCapDynBitrate | passTexture, true},
+	} {
+		if got := encoderReconfigurable(tc.caps); got != tc.want {
+			t.Errorf("encoderReconfigurable(%#x) = %v, want %v", tc.caps, got, tc.want)
+		}
+	}
+}
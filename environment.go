@@ -0,0 +1,73 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Neither libobs nor the frontend API tell plugins about safe or portable mode,
+// so we look at the same command line flags and marker files that OBS itself does.
+
+// portableMode and safeMode are set at load. Neither can change while OBS is running, so
+// they're worked out once rather than on every scrape.
+var portableMode, safeMode bool
+
+func detectStartupModes() {
+	exePath, _ := os.Executable()
+	portableMode = portableModeActive(os.Args, exePath)
+	safeMode = safeModeActive(os.Args)
+}
+
+var portableModeMarkers = []string{"portable_mode", "portable_mode.txt", "obs_portable_mode", "obs_portable_mode.txt"}
+
+func hasArg(args []string, flags ...string) bool {
+	for _, arg := range args {
+		for _, flag := range flags {
+			if arg == flag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// safeModeActive reports whether OBS was started in safe mode.
+// OBS doesn't load third-party plugins in safe mode, so in practice this is only seen if that changes.
+func safeModeActive(args []string) bool {
+	return hasArg(args, "--safe-mode")
+}
+
+// portableModeActive reports whether OBS was started in portable mode, either from the command line
+// or because a marker file exists next to the OBS install.
+func portableModeActive(args []string, exePath string) bool {
+	if hasArg(args, "--portable", "-p") {
+		return true
+	}
+	if exePath == "" {
+		return false
+	}
+	exeDir := filepath.Dir(exePath)
+	// On Windows the executable lives in bin/64bit, and the markers live at the top of the install.
+	for _, dir := range []string{exeDir, filepath.Join(exeDir, "..", "..")} {
+		for _, marker := range portableModeMarkers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSafeModeActive(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"obs"}, false},
+		{[]string{"obs", "--safe-mode"}, true},
+		{[]string{"obs", "--startstreaming", "--safe-mode"}, true},
+		{[]string{"obs", "--safe-mode=false"}, false},
+	} {
+		if got := safeModeActive(tc.args); got != tc.want {
+			t.Errorf("safeModeActive(%q) = %v, want %v", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestPortableModeActive(t *testing.T) {
+	install := t.TempDir()
+	bin := filepath.Join(install, "bin", "64bit")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(bin, "obs64.exe")
+
+	if portableModeActive([]string{"obs64.exe"}, exe) {
+		t.Error("portableModeActive = true without a flag or a marker file")
+	}
+	if portableModeActive([]string{"obs64.exe"}, "") {
+		t.Error("portableModeActive = true without a known executable")
+	}
+	for _, flag := range []string{"--portable", "-p"} {
+		if !portableModeActive([]string{"obs64.exe", flag}, "") {
+			t.Errorf("portableModeActive = false with %s", flag)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(install, "portable_mode.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !portableModeActive([]string{"obs64.exe"}, exe) {
+		t.Error("portableModeActive = false with a marker file at the top of the install")
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// #include <stdbool.h>
+import "C"
+
+import (
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics about the exporter itself.
+var (
+	goroutines = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: exporterSubsystem,
+		Name:      "goroutines",
+		Help:      "Number of goroutines running in the exporter.",
+	}, func() float64 { return float64(runtime.NumGoroutine()) })
+
+	unknownSourceEvents = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: exporterSubsystem,
+		Name:      "unknown_source_events_total",
+		Help:      "Volume meter updates received for sources the exporter isn't tracking.",
+	})
+
+	registrations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: exporterSubsystem,
+		Name:      "registrations_total",
+		Help:      "Times the metric collector has been registered, which happens each time the exporter is loaded.",
+	})
+
+	configReloads = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: exporterSubsystem,
+		Name:      "config_reloads_total",
+		Help:      "Times the exporter's settings have been applied, including when it was loaded.",
+	})
+
+	configLastReload = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: exporterSubsystem,
+		Name:      "config_last_reload_timestamp_seconds",
+		Help:      "Unix time the exporter's settings were last applied.",
+	})
+
+	sourceChurn = newSourceChurn()
+
+	loadDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: exporterSubsystem,
+		Name:      "load_duration_seconds",
+		Help:      "Time OBS spent loading the exporter.",
+	})
+)
+
+// Categories for exporterErrors.
+const (
+	errorVolmeterCreate = "volmeter_create"
+	errorVolmeterAttach = "volmeter_attach"
+	errorCollectPanic   = "collect_panic"
+	errorPortBind       = "port_bind"
+	errorConfigParse    = "config_parse"
+	errorConfigSave     = "config_save"
+)
+
+var exporterErrors = newExporterErrors()
+
+func newExporterErrors() *prometheus.CounterVec {
+	v := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: exporterSubsystem,
+		Name:      "errors_total",
+		Help:      "Errors encountered by the exporter, by category.",
+	}, []string{"category"})
+	for _, category := range []string{errorVolmeterCreate, errorVolmeterAttach, errorCollectPanic, errorPortBind, errorConfigParse, errorConfigSave} {
+		v.WithLabelValues(category)
+	}
+	return v
+}
+
+// observeLoadDuration records the time since start as how long loading took.
+func observeLoadDuration(start time.Time) {
+	loadDuration.Set(time.Since(start).Seconds())
+}
+
+func newSourceChurn() *prometheus.CounterVec {
+	v := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: exporterSubsystem,
+		Name:      "source_churn_total",
+		Help:      "Sources the exporter has started or stopped tracking, by direction.",
+	}, []string{"direction"})
+	v.WithLabelValues("added")
+	v.WithLabelValues("removed")
+	return v
+}
+
+func countError(category string) {
+	exporterErrors.WithLabelValues(category).Inc()
+}
+
+// recoverCollectPanic stops a panic during collection from taking OBS down with it.
+// It must be deferred directly. If ret is non-nil, it's set to false so that an OBS enumeration stops.
+func recoverCollectPanic(ret *C.bool) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	countError(errorCollectPanic)
+	slog.Error("panic while collecting metrics", "panic", r, "stack", string(debug.Stack()))
+	if ret != nil {
+		*ret = C.bool(false)
+	}
+}
+
+// Only warn about each unknown source every so often.
+var unknownSourceLogLimiter = newLogLimiter(10 * time.Second)
+
+// unknownSource counts a callback for a source we aren't tracking, and warns about it if we haven't recently.
+func unknownSource(callback, uuid string, now time.Time) {
+	unknownSourceEvents.Inc()
+	if unknownSourceLogLimiter.allow(uuid, now) {
+		slog.Warn("unknown source in "+callback, "source_uuid", uuid)
+	}
+}
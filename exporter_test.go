@@ -0,0 +1,99 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRegistrationsCountsReloads(t *testing.T) {
+	before := testutil.ToFloat64(registrations)
+
+	registerMetrics()
+	unregisterMetrics()
+	// This would panic if anything from the first load was still registered.
+	registerMetrics()
+	defer unregisterMetrics()
+
+	if got := testutil.ToFloat64(registrations) - before; got != 2 {
+		t.Errorf("registrations went up by %v after loading, unloading and loading again, want 2", got)
+	}
+}
+
+func TestGoroutines(t *testing.T) {
+	if got := testutil.ToFloat64(goroutines); got < 1 {
+		t.Errorf("obs_exporter_goroutines = %v, want at least 1", got)
+	}
+}
+
+func TestUnknownSourceLogging(t *testing.T) {
+	logs := recordLogs(t)
+	before := testutil.ToFloat64(unknownSourceEvents)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		unknownSource("test_callback", "uuid-1", now.Add(time.Duration(i)*time.Second))
+	}
+	// A different source isn't held back by the first one.
+	unknownSource("test_callback", "uuid-2", now)
+
+	if got := testutil.ToFloat64(unknownSourceEvents) - before; got != 6 {
+		t.Errorf("unknown_source_events_total went up by %v, want 6", got)
+	}
+	if got := len(logs.Messages()); got != 2 {
+		t.Errorf("logged %d lines (%q), want one for each source", got, logs.Messages())
+	}
+
+	unknownSource("test_callback", "uuid-1", now.Add(10*time.Second))
+	if got := len(logs.Messages()); got != 3 {
+		t.Errorf("logged %d lines, want another once the interval has passed", got)
+	}
+}
+
+func TestCountErrorByCategory(t *testing.T) {
+	categories := []string{errorVolmeterCreate, errorVolmeterAttach, errorCollectPanic, errorPortBind, errorConfigParse}
+	before := map[string]float64{}
+	for _, category := range categories {
+		before[category] = testutil.ToFloat64(exporterErrors.WithLabelValues(category))
+	}
+
+	for n, category := range categories {
+		for i := 0; i <= n; i++ {
+			countError(category)
+		}
+	}
+
+	for n, category := range categories {
+		got := testutil.ToFloat64(exporterErrors.WithLabelValues(category)) - before[category]
+		if want := float64(n + 1); got != want {
+			t.Errorf("errors_total{category=%q} went up by %v, want %v", category, got, want)
+		}
+	}
+}
+
+func TestObserveLoadDuration(t *testing.T) {
+	loadDuration.Set(0)
+	const step = 20 * time.Millisecond
+	func() {
+		// This is how obs_module_load times itself.
+		defer observeLoadDuration(time.Now())
+		time.Sleep(step)
+	}()
+	if got := testutil.ToFloat64(loadDuration); got < step.Seconds() {
+		t.Errorf("load duration = %vs, want at least the %v spent loading", got, step)
+	}
+}
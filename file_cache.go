@@ -0,0 +1,61 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// fileCache holds what was parsed from files we read while collecting, so they're only read
+// again once they change.
+type fileCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedFile
+}
+
+type cachedFile struct {
+	modTime time.Time
+	size    int64
+	value   interface{}
+	err     error
+}
+
+// get returns what parse returned for the contents of path, reading the file again only if its
+// modification time or size has changed since it was last read.
+func (fc *fileCache) get(path string, parse func([]byte) (interface{}, error)) (interface{}, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fi, err := os.Stat(path)
+	if err != nil {
+		delete(fc.entries, path)
+		return nil, err
+	}
+	if e, ok := fc.entries[path]; ok && e.modTime.Equal(fi.ModTime()) && e.size == fi.Size() {
+		return e.value, e.err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		delete(fc.entries, path)
+		return nil, err
+	}
+	v, err := parse(data)
+	if fc.entries == nil {
+		fc.entries = map[string]cachedFile{}
+	}
+	fc.entries[path] = cachedFile{modTime: fi.ModTime(), size: fi.Size(), value: v, err: err}
+	return v, err
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// writeSnapshot writes the metric families in the Prometheus text format, preceded by a comment with the snapshot time.
+func writeSnapshot(w io.Writer, mfs []*dto.MetricFamily, now time.Time) error {
+	if _, err := fmt.Fprintf(w, "# snapshot %s\n", now.UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(w, mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotateIfLarger moves path aside to path.1, replacing any previous one, once it's grown past maxBytes.
+func rotateIfLarger(path string, maxBytes int64) error {
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.Size() < maxBytes {
+		return nil
+	}
+	return os.Rename(path, path+".1")
+}
+
+// appendSnapshot gathers metrics from g and appends them to the file at path, rotating it first if it's too big.
+func appendSnapshot(g prometheus.Gatherer, path string, maxBytes int64) error {
+	mfs, err := g.Gather()
+	if err != nil {
+		return fmt.Errorf("gathering metrics: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := writeSnapshot(&buf, mfs, time.Now()); err != nil {
+		return err
+	}
+	if err := rotateIfLarger(path, maxBytes); err != nil {
+		return fmt.Errorf("rotating: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := buf.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runFileExporter(ctx context.Context, path string, interval time.Duration, maxBytes int64) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		heartbeat(ctx, interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := appendSnapshot(exportedMetrics, path, maxBytes); err != nil {
+			slog.Warn("writing metrics to file failed", "path", path, "err", err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestAppendSnapshot(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "A test gauge."})
+	reg.MustRegister(g)
+	path := filepath.Join(t.TempDir(), "metrics.prom")
+
+	g.Set(1)
+	if err := appendSnapshot(reg, path, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	g.Set(2)
+	if err := appendSnapshot(reg, path, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	if n := strings.Count(got, "# snapshot "); n != 2 {
+		t.Errorf("file has %d snapshots, want 2:\n%s", n, got)
+	}
+	first, second := strings.Index(got, "test_gauge 1\n"), strings.Index(got, "test_gauge 2\n")
+	if first < 0 || second < first {
+		t.Errorf("file doesn't have test_gauge 1 followed by test_gauge 2:\n%s", got)
+	}
+}
+
+func TestAppendSnapshotRotates(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "A test gauge."}))
+	path := filepath.Join(t.TempDir(), "metrics.prom")
+
+	for i := 0; i < 3; i++ {
+		// Anything already written is over the limit, so each write starts a new file.
+		if err := appendSnapshot(reg, path, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []string{path, path + ".1"} {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(b), "# snapshot "); n != 1 {
+			t.Errorf("%s has %d snapshots, want 1", filepath.Base(p), n)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <obs.h>
+
+typedef bool (*mc_enum_sources_proc)(void*, obs_source_t*);
+typedef bool (*mc_enum_scenes_proc)(void*, obs_source_t*);
+
+bool mc_enum_sources_cb(void*, obs_source_t*);
+bool mc_enum_scenes_cb(void*, obs_source_t*);
+void mc_enum_filters_cb(obs_source_t*, obs_source_t*, void*);
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+// snapshotActiveFilters counts the enabled filters on every source and scene.
+func (c *MetricCollector) snapshotActiveFilters() int {
+	var sources [][]bool
+	c.enumFiltersCB = func(parent, child *C.obs_source_t, v unsafe.Pointer) {
+		last := &sources[len(sources)-1]
+		*last = append(*last, bool(C.obs_source_enabled(child)))
+	}
+	readFilters := func(v unsafe.Pointer, o *C.obs_source_t) C.bool {
+		sources = append(sources, nil)
+		C.obs_source_enum_filters(o, C.obs_source_enum_proc_t(C.mc_enum_filters_cb), nil)
+		return C.bool(true)
+	}
+	c.enumSourcesCB = readFilters
+	C.obs_enum_sources(C.mc_enum_sources_proc(C.mc_enum_sources_cb), nil)
+	c.enumScenesCB = readFilters
+	C.obs_enum_scenes(C.mc_enum_scenes_proc(C.mc_enum_scenes_cb), nil)
+	return countActiveFilters(sources)
+}
+
+// countActiveFilters counts the enabled filters, given whether each filter on each source is enabled.
+func countActiveFilters(sources [][]bool) int {
+	var active int
+	for _, filters := range sources {
+		for _, enabled := range filters {
+			if enabled {
+				active++
+			}
+		}
+	}
+	return active
+}
@@ -0,0 +1,42 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestCountActiveFilters(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		sources [][]bool
+		want    int
+	}{
+		{"no sources", nil, 0},
+		{"no filters", [][]bool{nil, {}}, 0},
+		{"mixed", [][]bool{
+			// A mic with noise suppression and a disabled gain filter.
+			{true, false},
+			// A camera with colour correction and a LUT.
+			{true, true},
+			// A scene with nothing on it.
+			nil,
+			// Everything disabled on the desktop audio.
+			{false, false, false},
+		}, 3},
+	} {
+		if got := countActiveFilters(tc.sources); got != tc.want {
+			t.Errorf("%s: countActiveFilters = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
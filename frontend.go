@@ -0,0 +1,185 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs -Ithird_party/obs-studio/UI/obs-frontend-api
+#include <stdlib.h>
+#include <obs-frontend-api.h>
+#include <util/config-file.h>
+*/
+import "C"
+
+import (
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// shuttingDown is set once OBS starts tearing down, after which Collect must not call into OBS.
+// It's only set while holding obsLock, so no collection can still be in progress once it's true.
+var shuttingDown atomic.Bool
+
+// obsLoaded is set once OBS has finished starting up, including loading its scene collection.
+// It isn't cleared on unload, since OBS won't say it has finished loading again.
+var obsLoaded atomic.Bool
+
+// frontendAvailable is set at load if OBS's frontend is running. libobs can be embedded without
+// it, in which case the frontend API is missing or does nothing useful, so frontend metrics are skipped.
+var frontendAvailable bool
+
+var frontendAvailableGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: frontendSubsystem,
+	Name:      "available",
+	Help:      "Whether OBS's frontend is running, so frontend metrics can be exported.",
+})
+
+// probeFrontend reports whether the frontend is running. The frontend API can be there without
+// the frontend, in which case it returns nothing.
+func probeFrontend() bool {
+	if !loadFrontendAPI() {
+		return false
+	}
+	if frontendMainWindow() == nil {
+		unloadFrontendAPI()
+		return false
+	}
+	return true
+}
+
+var lastSceneChange = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: frontendSubsystem,
+	Name:      "last_scene_change_timestamp_seconds",
+	Help:      "Unix time the program scene last changed.",
+})
+
+// profileConfig calls get with the current profile's basic.ini and the C strings for section and name.
+// It returns false if there's no current profile.
+func profileConfig(section, name string, get func(cfg *C.config_t, section, name *C.char)) bool {
+	cfg := frontendProfileConfig()
+	if cfg == nil {
+		return false
+	}
+	sectionC := C.CString(section)
+	defer C.free(unsafe.Pointer(sectionC))
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+	get(cfg, sectionC, nameC)
+	return true
+}
+
+// profileConfigString reads a value from the current profile's basic.ini.
+func profileConfigString(section, name string) string {
+	var v string
+	profileConfig(section, name, func(cfg *C.config_t, section, name *C.char) {
+		v = C.GoString(C.config_get_string(cfg, section, name))
+	})
+	return v
+}
+
+// outputMode normalizes the profile's Output/Mode setting.
+func outputMode(mode string) string {
+	switch mode {
+	case "Simple", "Advanced":
+		return mode
+	}
+	return "Unknown"
+}
+
+// outputModeSection is the basic.ini section holding the settings for an output mode.
+func outputModeSection(mode string) string {
+	switch mode {
+	case "Simple":
+		return "SimpleOutput"
+	case "Advanced":
+		return "AdvOut"
+	}
+	return ""
+}
+
+// configBool parses a boolean config value the way config_get_bool does.
+func configBool(v string) bool {
+	if v == "true" {
+		return true
+	}
+	n, _ := strconv.ParseInt(v, 10, 64)
+	return n != 0
+}
+
+// replayBufferLength returns the configured maximum replay buffer length in seconds, given a
+// lookup into the profile's basic.ini, or false if the replay buffer isn't enabled.
+func replayBufferLength(mode string, get func(section, name string) string) (int64, bool) {
+	section := outputModeSection(mode)
+	if section == "" || !configBool(get(section, "RecRB")) {
+		return 0, false
+	}
+	length, _ := strconv.ParseInt(get(section, "RecRBTime"), 10, 64)
+	return length, true
+}
+
+func registerFrontendCallbacks() {
+	if !frontendAvailable {
+		return
+	}
+	addFrontendEventCallback()
+	registerDebugDumpHotkey()
+}
+
+func unregisterFrontendCallbacks() {
+	if !frontendAvailable {
+		return
+	}
+	removeFrontendEventCallback()
+	unregisterDebugDumpHotkey()
+	disconnectOutputStopSignals()
+	unloadFrontendAPI()
+}
+
+func beginShutdown() {
+	obsLock.Lock()
+	defer obsLock.Unlock()
+	if !shuttingDown.Swap(true) {
+		slog.Info("OBS is shutting down; no longer collecting metrics")
+	}
+}
+
+// frontendEventSceneChanged is OBS_FRONTEND_EVENT_SCENE_CHANGED, for code that can't use cgo.
+const frontendEventSceneChanged = C.OBS_FRONTEND_EVENT_SCENE_CHANGED
+
+func handleFrontendEvent(event C.enum_obs_frontend_event) {
+	switch event {
+	case C.OBS_FRONTEND_EVENT_SCRIPTING_SHUTDOWN, C.OBS_FRONTEND_EVENT_EXIT:
+		beginShutdown()
+	case C.OBS_FRONTEND_EVENT_FINISHED_LOADING:
+		obsLoaded.Store(true)
+	case C.OBS_FRONTEND_EVENT_SCENE_CHANGED:
+		lastSceneChange.SetToCurrentTime()
+	// The output may not have been set up when it's starting, so try again once it's started.
+	case C.OBS_FRONTEND_EVENT_STREAMING_STARTING, C.OBS_FRONTEND_EVENT_STREAMING_STARTED:
+		connectOutputStop(frontendStreamingOutput(), stopKindStreaming)
+	case C.OBS_FRONTEND_EVENT_RECORDING_STARTING, C.OBS_FRONTEND_EVENT_RECORDING_STARTED:
+		connectOutputStop(frontendRecordingOutput(), stopKindRecording)
+	}
+}
+
+//export mc_frontend_event_cb_go
+func mc_frontend_event_cb_go(event C.int, data unsafe.Pointer) {
+	handleFrontendEvent(C.enum_obs_frontend_event(event))
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs -Ithird_party/obs-studio/UI/obs-frontend-api
+#include <string.h>
+#include <obs-frontend-api.h>
+#include <util/platform.h>
+#ifndef _WIN32
+#include <dlfcn.h>
+#endif
+
+// The frontend API is looked up when the module loads, rather than linked against, so the
+// module still loads where libobs is embedded without OBS's frontend.
+#define MC_FRONTEND_FUNCS(X) \
+	X(obs_frontend_get_main_window) \
+	X(obs_frontend_get_profile_config) \
+	X(obs_frontend_get_current_profile_path) \
+	X(obs_frontend_add_event_callback) \
+	X(obs_frontend_remove_event_callback) \
+	X(obs_frontend_streaming_active) \
+	X(obs_frontend_recording_active) \
+	X(obs_frontend_recording_paused) \
+	X(obs_frontend_get_streaming_output) \
+	X(obs_frontend_get_recording_output) \
+	X(obs_frontend_get_current_scene) \
+	X(obs_frontend_get_current_preview_scene) \
+	X(obs_frontend_preview_program_mode_active)
+
+void mc_frontend_event_cb(enum obs_frontend_event event, void *data);
+
+static void *mc_frontend_lib;
+
+static struct {
+#define X(name) __typeof__(name) *name;
+	MC_FRONTEND_FUNCS(X)
+#undef X
+} mc_frontend;
+
+static bool mc_frontend_load(void) {
+#ifdef _WIN32
+	mc_frontend_lib = os_dlopen("obs-frontend-api");
+#else
+	// OBS links against the frontend API itself, so it's already in the global scope.
+	mc_frontend_lib = dlopen(NULL, RTLD_LAZY);
+#endif
+	if (!mc_frontend_lib)
+		return false;
+	bool ok = true;
+#define X(name) \
+	mc_frontend.name = os_dlsym(mc_frontend_lib, #name); \
+	ok = ok && mc_frontend.name;
+	MC_FRONTEND_FUNCS(X)
+#undef X
+	return ok;
+}
+
+static void mc_frontend_unload(void) {
+	if (mc_frontend_lib)
+		os_dlclose(mc_frontend_lib);
+	mc_frontend_lib = NULL;
+	memset(&mc_frontend, 0, sizeof(mc_frontend));
+}
+
+static void *mc_frontend_get_main_window(void) { return mc_frontend.obs_frontend_get_main_window(); }
+static config_t *mc_frontend_get_profile_config(void) { return mc_frontend.obs_frontend_get_profile_config(); }
+static char *mc_frontend_get_current_profile_path(void) { return mc_frontend.obs_frontend_get_current_profile_path(); }
+static void mc_frontend_add_event_callback(obs_frontend_event_cb cb, void *data) { mc_frontend.obs_frontend_add_event_callback(cb, data); }
+static void mc_frontend_remove_event_callback(obs_frontend_event_cb cb, void *data) { mc_frontend.obs_frontend_remove_event_callback(cb, data); }
+static bool mc_frontend_streaming_active(void) { return mc_frontend.obs_frontend_streaming_active(); }
+static bool mc_frontend_recording_active(void) { return mc_frontend.obs_frontend_recording_active(); }
+static bool mc_frontend_recording_paused(void) { return mc_frontend.obs_frontend_recording_paused(); }
+static obs_output_t *mc_frontend_get_streaming_output(void) { return mc_frontend.obs_frontend_get_streaming_output(); }
+static obs_output_t *mc_frontend_get_recording_output(void) { return mc_frontend.obs_frontend_get_recording_output(); }
+static obs_source_t *mc_frontend_get_current_scene(void) { return mc_frontend.obs_frontend_get_current_scene(); }
+static obs_source_t *mc_frontend_get_current_preview_scene(void) { return mc_frontend.obs_frontend_get_current_preview_scene(); }
+static bool mc_frontend_preview_program_mode_active(void) { return mc_frontend.obs_frontend_preview_program_mode_active(); }
+*/
+import "C"
+
+import "unsafe"
+
+// loadFrontendAPI looks up OBS's frontend API. It returns false if it isn't there, in which case
+// none of the other functions here can be called.
+func loadFrontendAPI() bool {
+	if C.mc_frontend_load() {
+		return true
+	}
+	C.mc_frontend_unload()
+	return false
+}
+
+func unloadFrontendAPI() {
+	C.mc_frontend_unload()
+}
+
+func frontendMainWindow() unsafe.Pointer {
+	return C.mc_frontend_get_main_window()
+}
+
+func frontendProfileConfig() *C.config_t {
+	return C.mc_frontend_get_profile_config()
+}
+
+// frontendCurrentProfilePath returns the current profile's directory, which must be freed with bfree.
+func frontendCurrentProfilePath() *C.char {
+	return C.mc_frontend_get_current_profile_path()
+}
+
+func addFrontendEventCallback() {
+	C.mc_frontend_add_event_callback(C.obs_frontend_event_cb(C.mc_frontend_event_cb), nil)
+}
+
+func removeFrontendEventCallback() {
+	C.mc_frontend_remove_event_callback(C.obs_frontend_event_cb(C.mc_frontend_event_cb), nil)
+}
+
+// frontendOutputState returns whether the frontend's streaming and recording outputs are running,
+// and whether the recording is paused.
+func frontendOutputState() (streaming, recording, paused bool) {
+	return bool(C.mc_frontend_streaming_active()), bool(C.mc_frontend_recording_active()), bool(C.mc_frontend_recording_paused())
+}
+
+// frontendStreamingOutput returns a new reference to the frontend's streaming output, or nil.
+func frontendStreamingOutput() *C.obs_output_t {
+	return C.mc_frontend_get_streaming_output()
+}
+
+// frontendRecordingOutput returns a new reference to the frontend's recording output, or nil.
+func frontendRecordingOutput() *C.obs_output_t {
+	return C.mc_frontend_get_recording_output()
+}
+
+// frontendCurrentScene returns a new reference to the program scene, or nil.
+func frontendCurrentScene() *C.obs_source_t {
+	return C.mc_frontend_get_current_scene()
+}
+
+// frontendCurrentPreviewScene returns a new reference to studio mode's preview scene, or nil.
+func frontendCurrentPreviewScene() *C.obs_source_t {
+	return C.mc_frontend_get_current_preview_scene()
+}
+
+func frontendStudioMode() bool {
+	return bool(C.mc_frontend_preview_program_mode_active())
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestFrontendMetricsNeedFrontend(t *testing.T) {
+	c := newTestCollector(t)
+
+	for _, hasFrontend := range []bool{false, true} {
+		snap := &collectorSnapshot{Up: true}
+		snap.Global.HasFrontend = hasFrontend
+		snap.Global.ProgramScene.Name = "Scene"
+		var frontend []string
+		for _, m := range emitSnapshot(t, c, snap) {
+			if strings.HasPrefix(m.Name, "obs_frontend_") {
+				frontend = append(frontend, m.Name)
+			}
+		}
+		if hasFrontend && len(frontend) == 0 {
+			t.Error("no frontend metrics with the frontend running")
+		}
+		if !hasFrontend && len(frontend) != 0 {
+			t.Errorf("frontend metrics %v without the frontend running", frontend)
+		}
+	}
+}
+
+func TestOutputMode(t *testing.T) {
+	for mode, want := range map[string]string{
+		"Simple":   "Simple",
+		"Advanced": "Advanced",
+		"":         "Unknown",
+		"simple":   "Unknown",
+		"Custom":   "Unknown",
+	} {
+		if got := outputMode(mode); got != want {
+			t.Errorf("outputMode(%q) = %q, want %q", mode, got, want)
+		}
+	}
+}
+
+func TestReplayBufferLength(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		mode   string
+		config map[string]string
+		want   int64
+		ok     bool
+	}{
+		{"simple", "Simple", map[string]string{"SimpleOutput/RecRB": "true", "SimpleOutput/RecRBTime": "20"}, 20, true},
+		{"advanced", "Advanced", map[string]string{"AdvOut/RecRB": "true", "AdvOut/RecRBTime": "45"}, 45, true},
+		{"numeric bool", "Advanced", map[string]string{"AdvOut/RecRB": "1", "AdvOut/RecRBTime": "45"}, 45, true},
+		{"disabled", "Simple", map[string]string{"SimpleOutput/RecRB": "false", "SimpleOutput/RecRBTime": "20"}, 0, false},
+		{"other mode's section", "Simple", map[string]string{"AdvOut/RecRB": "true", "AdvOut/RecRBTime": "45"}, 0, false},
+		{"unknown mode", "Unknown", map[string]string{"SimpleOutput/RecRB": "true", "SimpleOutput/RecRBTime": "20"}, 0, false},
+	} {
+		get := func(section, name string) string { return tc.config[section+"/"+name] }
+		got, ok := replayBufferLength(tc.mode, get)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%s: replayBufferLength = %d, %v; want %d, %v", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestSceneChangedEventUpdatesTimestamp(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(lastSceneChange)
+	lastSceneChange.Set(0)
+
+	before := time.Now()
+	handleFrontendEvent(frontendEventSceneChanged)
+	after := time.Now()
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 1 || len(mfs[0].GetMetric()) != 1 {
+		t.Fatalf("gathered %v, want just obs_frontend_last_scene_change_timestamp_seconds", mfs)
+	}
+	got := mfs[0].GetMetric()[0].GetGauge().GetValue()
+	if got < float64(before.UnixNano())/1e9 || got > float64(after.UnixNano())/1e9 {
+		t.Errorf("last scene change = %v, want between %v and %v", got, float64(before.UnixNano())/1e9, float64(after.UnixNano())/1e9)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var listening = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: exporterSubsystem,
+	Name:      "listening",
+	Help:      "Addresses the exporter is serving HTTP on.",
+}, []string{"address", "port"})
+
+var observedScrapeInterval = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: exporterSubsystem,
+	Name:      "observed_scrape_interval_seconds",
+	Help:      "Time between the last two requests for /metrics.",
+})
+
+// scrapeTracker remembers when /metrics was last requested.
+type scrapeTracker struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// observe records a scrape and returns the time since the previous one, or false if this is the first.
+func (t *scrapeTracker) observe(now time.Time) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last := t.last
+	t.last = now
+	if last.IsZero() {
+		return 0, false
+	}
+	return now.Sub(last), true
+}
+
+// trackScrapes updates observedScrapeInterval before each request to next,
+// so the scrape reports the interval that ended with it.
+func trackScrapes(next http.Handler) http.Handler {
+	var t scrapeTracker
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if interval, ok := t.observe(time.Now()); ok {
+			observedScrapeInterval.Set(interval.Seconds())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+var (
+	serversMu sync.Mutex
+	servers   []*http.Server
+)
+
+// serveMux routes the requests every listener serves. It's created on each load rather than
+// using http.DefaultServeMux, which can't have its handlers removed when we're unloaded.
+var serveMux *http.ServeMux
+
+func newServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "You have reached obs-studio-exporter. Please leave a message after the beep.")
+	})
+	mux.Handle("/metrics", trackScrapes(promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(exportedMetrics, promhttp.HandlerOpts{}))))
+	mux.HandleFunc("/metrics.json", metricsJSONHandler)
+	mux.HandleFunc("/ready", readyHandler)
+	mux.HandleFunc("/prometheus.yml", prometheusConfigHandler)
+	if activeConfig.LogTailUsername != "" && activeConfig.LogTailPassword != "" {
+		logTail = newLogRing(activeConfig.LogTailLines)
+		mux.Handle("/logtail", basicAuth(activeConfig.LogTailUsername, activeConfig.LogTailPassword, http.HandlerFunc(logTailHandler)))
+	}
+	return mux
+}
+
+// newServer returns a server which will be shut down when the module is unloaded.
+// A nil handler serves serveMux.
+func newServer(addr string, tlsConfig *tls.Config, handler http.Handler) *http.Server {
+	if handler == nil {
+		handler = serveMux
+	}
+	srv := &http.Server{Addr: addr, TLSConfig: tlsConfig, Handler: handler}
+	serversMu.Lock()
+	defer serversMu.Unlock()
+	servers = append(servers, srv)
+	return srv
+}
+
+// shutdownServers waits up to timeout for in-flight requests to finish, then closes any servers that are still busy.
+func shutdownServers(timeout time.Duration) {
+	serversMu.Lock()
+	defer serversMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			slog.Warn("HTTP server didn't shut down gracefully, closing it", "address", srv.Addr, "timeout", timeout, "err", err)
+			srv.Close()
+			continue
+		}
+		slog.Info("HTTP server shut down gracefully", "address", srv.Addr)
+	}
+	servers = nil
+	// The listeners started next set these again, for the certificates they're using.
+	tlsEnabled.Set(0)
+	tlsCertExpiry.Reset()
+}
+
+func addrPort(addr net.Addr) int {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.Port
+	}
+	return 0
+}
+
+// serveListener serves HTTP, or HTTPS if tlsConfig is set, on an already bound listener until it's closed.
+func serveListener(ln net.Listener, tlsConfig *tls.Config, handler http.Handler) {
+	port := addrPort(ln.Addr())
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+		tlsEnabled.Set(1)
+	}
+	slog.Info("Listening for HTTP", "address", ln.Addr().String(), "port", port)
+	listening.WithLabelValues(ln.Addr().String(), strconv.Itoa(port)).Set(1)
+	srv := newServer(ln.Addr().String(), tlsConfig, handler)
+	go func() {
+		err := srv.Serve(ln)
+		listening.DeleteLabelValues(ln.Addr().String(), strconv.Itoa(port))
+		if errors.Is(err, http.ErrServerClosed) {
+			return
+		}
+		slog.Error("http.Serve failed", "address", ln.Addr().String(), "err", err)
+	}()
+}
+
+// readyHandler returns 200 once OBS has finished loading and our metrics are registered, and 503
+// before then or once OBS is shutting down. It doesn't read anything from OBS, so polling it
+// doesn't disturb the metrics, which are partly worked out from the time between scrapes.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	c := activeMetricCollector
+	if c == nil || !obsLoaded.Load() || !c.sourcesEnumerated.Load() || shuttingDown.Load() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
+
+// prometheusConfigHandler serves a Prometheus scrape config pointing at the address this request was received on.
+func prometheusConfigHandler(w http.ResponseWriter, r *http.Request) {
+	host, port := r.Host, ""
+	if h, p, err := net.SplitHostPort(r.Host); err == nil {
+		host, port = h, p
+	}
+	if localAddr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		if p := addrPort(localAddr); p != 0 {
+			port = strconv.Itoa(p)
+		}
+	}
+	target := host
+	if port != "" {
+		target = net.JoinHostPort(host, port)
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	fmt.Fprintf(w, "scrape_configs:\n  - job_name: obs_studio\n")
+	if r.TLS != nil {
+		fmt.Fprintf(w, "    scheme: https\n")
+	}
+	fmt.Fprintf(w, "    static_configs:\n      - targets: ['%s']\n", target)
+}
@@ -0,0 +1,188 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestReadyHandler(t *testing.T) {
+	defer func(c *MetricCollector, loaded, shutdown bool) {
+		activeMetricCollector = c
+		obsLoaded.Store(loaded)
+		shuttingDown.Store(shutdown)
+	}(activeMetricCollector, obsLoaded.Load(), shuttingDown.Load())
+
+	srv := httptest.NewServer(http.HandlerFunc(readyHandler))
+	defer srv.Close()
+	check := func(when string, want int) {
+		t.Helper()
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("GET /ready %s: %v", when, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET /ready %s = %d, want %d", when, resp.StatusCode, want)
+		}
+	}
+
+	activeMetricCollector = nil
+	obsLoaded.Store(false)
+	shuttingDown.Store(false)
+	check("before the collector is registered", http.StatusServiceUnavailable)
+
+	activeMetricCollector = NewMetricCollector()
+	check("while OBS is loading", http.StatusServiceUnavailable)
+
+	obsLoaded.Store(true)
+	check("before the sources have been enumerated", http.StatusServiceUnavailable)
+
+	activeMetricCollector.sourcesEnumerated.Store(true)
+	check("once OBS has loaded and its sources have been enumerated", http.StatusOK)
+
+	shuttingDown.Store(true)
+	check("while OBS is shutting down", http.StatusServiceUnavailable)
+}
+
+func TestServeListenerReportsBoundPort(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(listening)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveListener(ln, nil, http.NotFoundHandler())
+	defer shutdownServers(time.Second)
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var port string
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "port" {
+					port = l.GetValue()
+				}
+			}
+		}
+	}
+	if port == "" || port == "0" {
+		t.Fatalf("obs_exporter_listening port = %q, want the port the OS picked", port)
+	}
+	resp, err := http.Get("http://127.0.0.1:" + port + "/")
+	if err != nil {
+		t.Fatalf("GET on the reported port: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestShutdownServersForcesStuckRequests(t *testing.T) {
+	logs := recordLogs(t)
+	started := make(chan struct{})
+	stuck := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		// This only returns once the connection is closed underneath it.
+		<-r.Context().Done()
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveListener(ln, nil, stuck)
+
+	reqErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		reqErr <- err
+	}()
+	<-started
+
+	const timeout = 100 * time.Millisecond
+	start := time.Now()
+	shutdownServers(timeout)
+	if took := time.Since(start); took < timeout || took > 5*time.Second {
+		t.Errorf("shutdownServers(%v) took %v", timeout, took)
+	}
+	if err := <-reqErr; err == nil {
+		t.Error("the stuck request succeeded, want its connection closed")
+	}
+	forced := false
+	for _, m := range logs.Messages() {
+		if m == "HTTP server didn't shut down gracefully, closing it" {
+			forced = true
+		}
+	}
+	if !forced {
+		t.Errorf("didn't log a forced close, logged %q", logs.Messages())
+	}
+}
+
+func TestScrapeTrackerObserve(t *testing.T) {
+	var st scrapeTracker
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, ok := st.observe(start); ok {
+		t.Error("first scrape reported an interval")
+	}
+	if got, ok := st.observe(start.Add(15 * time.Second)); !ok || got != 15*time.Second {
+		t.Errorf("second scrape interval = %v, %v, want 15s, true", got, ok)
+	}
+	if got, ok := st.observe(start.Add(45 * time.Second)); !ok || got != 30*time.Second {
+		t.Errorf("third scrape interval = %v, %v, want 30s, true", got, ok)
+	}
+}
+
+func TestTrackScrapesSetsInterval(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(observedScrapeInterval)
+	observedScrapeInterval.Set(0)
+
+	srv := httptest.NewServer(trackScrapes(http.NotFoundHandler()))
+	defer srv.Close()
+	scrape := func() {
+		t.Helper()
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	const wait = 50 * time.Millisecond
+	scrape()
+	time.Sleep(wait)
+	scrape()
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 1 {
+		t.Fatalf("gathered %d families, want 1", len(mfs))
+	}
+	if got := mfs[0].GetMetric()[0].GetGauge().GetValue(); got < wait.Seconds() {
+		t.Errorf("observed scrape interval = %vs after two requests %v apart", got, wait)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var influxFailures = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: exporterSubsystem,
+	Name:      "influx_failures_total",
+	Help:      "Failed writes to InfluxDB.",
+})
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// writeInfluxLine writes one point in InfluxDB line protocol. Label values become tags, except
+// empty ones, which InfluxDB doesn't allow. Points with no finite fields are skipped.
+func writeInfluxLine(w io.Writer, measurement string, labels []*dto.LabelPair, fields [][2]string, ts time.Time) error {
+	var b strings.Builder
+	b.WriteString(influxMeasurementEscaper.Replace(measurement))
+	for _, l := range labels {
+		if l.GetValue() == "" {
+			continue
+		}
+		fmt.Fprintf(&b, ",%s=%s", influxTagEscaper.Replace(l.GetName()), influxTagEscaper.Replace(l.GetValue()))
+	}
+	sep := " "
+	for _, f := range fields {
+		b.WriteString(sep)
+		fmt.Fprintf(&b, "%s=%s", influxTagEscaper.Replace(f[0]), f[1])
+		sep = ","
+	}
+	if sep == " " {
+		return nil
+	}
+	fmt.Fprintf(&b, " %d\n", ts.UnixNano())
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// influxFields returns the fields to write for a metric: value for gauges, counters and
+// untyped metrics, and count and sum for histograms and summaries. InfluxDB can't store
+// NaN or infinite floats, so those are left out.
+func influxFields(t dto.MetricType, m *dto.Metric) [][2]string {
+	var fields [][2]string
+	add := func(name string, v float64) {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return
+		}
+		fields = append(fields, [2]string{name, strconv.FormatFloat(v, 'g', -1, 64)})
+	}
+	switch t {
+	case dto.MetricType_GAUGE:
+		add("value", m.GetGauge().GetValue())
+	case dto.MetricType_COUNTER:
+		add("value", m.GetCounter().GetValue())
+	case dto.MetricType_UNTYPED:
+		add("value", m.GetUntyped().GetValue())
+	case dto.MetricType_HISTOGRAM:
+		add("count", float64(m.GetHistogram().GetSampleCount()))
+		add("sum", m.GetHistogram().GetSampleSum())
+	case dto.MetricType_SUMMARY:
+		add("count", float64(m.GetSummary().GetSampleCount()))
+		add("sum", m.GetSummary().GetSampleSum())
+	}
+	return fields
+}
+
+// writeInfluxLines writes gathered metric families in InfluxDB line protocol, with one
+// measurement per metric family.
+func writeInfluxLines(w io.Writer, mfs []*dto.MetricFamily, now time.Time) error {
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			ts := now
+			if m.TimestampMs != nil {
+				ts = time.UnixMilli(m.GetTimestampMs())
+			}
+			if err := writeInfluxLine(w, mf.GetName(), m.GetLabel(), influxFields(mf.GetType(), m), ts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// influxWriteURL returns the InfluxDB v2 write API URL for a server, bucket and organization.
+func influxWriteURL(server, bucket, org string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v2/write"
+	q := u.Query()
+	q.Set("bucket", bucket)
+	if org != "" {
+		q.Set("org", org)
+	}
+	q.Set("precision", "ns")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func exportInflux(ctx context.Context, writeURL, token string) error {
+	mfs, err := exportedMetrics.Gather()
+	if err != nil {
+		return fmt.Errorf("gathering metrics: %w", err)
+	}
+	var body bytes.Buffer
+	if err := writeInfluxLines(&body, mfs, time.Now()); err != nil {
+		return fmt.Errorf("encoding line protocol: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, writeURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("InfluxDB returned %s", resp.Status)
+	}
+	return nil
+}
+
+func runInfluxExporter(ctx context.Context, server, bucket, org, token string, interval time.Duration) {
+	writeURL, err := influxWriteURL(server, bucket, org)
+	if err != nil {
+		countError(errorConfigParse)
+		slog.Warn("invalid InfluxDB URL, not exporting to InfluxDB", "url", server, "err", err)
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		heartbeat(ctx, interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := exportInflux(ctx, writeURL, token); err != nil && ctx.Err() == nil {
+			influxFailures.Inc()
+			slog.Warn("InfluxDB export failed", "url", server, "bucket", bucket, "err", err)
+		}
+	}
+}
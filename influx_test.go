@@ -0,0 +1,105 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestWriteInfluxLines(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	peak := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "obs_source_channel_peak", Help: "Peak."}, []string{"source_name", "channel_id"})
+	frames := prometheus.NewCounter(prometheus.CounterOpts{Name: "obs_global_total_frames", Help: "Frames."})
+	hist := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "obs_skipped", Help: "Skipped."})
+	reg.MustRegister(peak, frames, hist)
+	peak.WithLabelValues("Mic, Desk", "0").Set(-6)
+	peak.WithLabelValues("Unnamed", "").Set(-12)
+	// InfluxDB can't store infinities, so silent channels have no point at all.
+	peak.WithLabelValues("Silent", "0").Set(math.Inf(-1))
+	frames.Add(42)
+	hist.Observe(1.5)
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeInfluxLines(&buf, mfs, time.Unix(200, 0)); err != nil {
+		t.Fatalf("writeInfluxLines: %v", err)
+	}
+	want := strings.Join([]string{
+		`obs_global_total_frames value=42 200000000000`,
+		`obs_skipped count=1,sum=1.5 200000000000`,
+		`obs_source_channel_peak,source_name=Unnamed value=-12 200000000000`,
+		`obs_source_channel_peak,channel_id=0,source_name=Mic\,\ Desk value=-6 200000000000`,
+	}, "\n") + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("writeInfluxLines wrote:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestInfluxWriteURL(t *testing.T) {
+	for _, tc := range []struct {
+		server, bucket, org, want string
+	}{
+		{"http://localhost:8086", "obs", "", "http://localhost:8086/api/v2/write?bucket=obs&precision=ns"},
+		{"https://influx.example.com/prefix/", "obs", "home", "https://influx.example.com/prefix/api/v2/write?bucket=obs&org=home&precision=ns"},
+	} {
+		got, err := influxWriteURL(tc.server, tc.bucket, tc.org)
+		if err != nil || got != tc.want {
+			t.Errorf("influxWriteURL(%q, %q, %q) = %q, %v, want %q", tc.server, tc.bucket, tc.org, got, err, tc.want)
+		}
+	}
+	if _, err := influxWriteURL("://", "obs", ""); err == nil {
+		t.Error("influxWriteURL accepted an invalid URL")
+	}
+}
+
+func TestExportInflux(t *testing.T) {
+	var gotAuth string
+	var gotBody []byte
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	if err := exportInflux(context.Background(), srv.URL, "secret"); err != nil {
+		t.Fatalf("exportInflux: %v", err)
+	}
+	if gotAuth != "Token secret" {
+		t.Errorf("Authorization = %q, want %q", gotAuth, "Token secret")
+	}
+	if len(gotBody) == 0 {
+		t.Error("no line protocol was written")
+	}
+
+	status = http.StatusUnauthorized
+	if err := exportInflux(context.Background(), srv.URL, "wrong"); err == nil {
+		t.Error("exportInflux succeeded though InfluxDB rejected the write")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// jsonSample is a single sample in /metrics.json. Histograms and summaries are flattened
+// into samples named like they are in the Prometheus text format.
+type jsonSample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Value  jsonValue         `json:"value"`
+}
+
+// jsonValue is a float64 which encodes non-finite values, like the -Inf of a silent audio channel, as strings.
+type jsonValue float64
+
+func (v jsonValue) MarshalJSON() ([]byte, error) {
+	f := float64(v)
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return json.Marshal(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return json.Marshal(f)
+}
+
+func jsonLabels(pairs []*dto.LabelPair, extra ...string) map[string]string {
+	labels := map[string]string{}
+	for _, lp := range pairs {
+		labels[lp.GetName()] = lp.GetValue()
+	}
+	for n := 0; n+1 < len(extra); n += 2 {
+		labels[extra[n]] = extra[n+1]
+	}
+	return labels
+}
+
+func jsonFromFamilies(mfs []*dto.MetricFamily) []jsonSample {
+	samples := []jsonSample{}
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			add := func(name string, v float64, extra ...string) {
+				samples = append(samples, jsonSample{Name: name, Labels: jsonLabels(m.GetLabel(), extra...), Value: jsonValue(v)})
+			}
+			switch mf.GetType() {
+			case dto.MetricType_GAUGE:
+				add(name, m.GetGauge().GetValue())
+			case dto.MetricType_COUNTER:
+				add(name, m.GetCounter().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add(name+"_bucket", float64(b.GetCumulativeCount()), "le", strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64))
+				}
+				add(name+"_bucket", float64(h.GetSampleCount()), "le", "+Inf")
+				add(name+"_sum", h.GetSampleSum())
+				add(name+"_count", float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(name, q.GetValue(), "quantile", strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64))
+				}
+				add(name+"_sum", s.GetSampleSum())
+				add(name+"_count", float64(s.GetSampleCount()))
+			}
+		}
+	}
+	return samples
+}
+
+// metricsJSONHandler serves all metrics as a JSON array, for consumers that don't speak the Prometheus format.
+func metricsJSONHandler(w http.ResponseWriter, r *http.Request) {
+	mfs, err := exportedMetrics.Gather()
+	if err != nil {
+		slog.Warn("failed to gather metrics for /metrics.json", "err", err)
+		if len(mfs) == 0 {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(jsonFromFamilies(mfs)); err != nil {
+		slog.Warn("failed to write /metrics.json", "err", err)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricsJSONHandler(t *testing.T) {
+	muted := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "obs_test_channel_magnitude",
+		Help: "A silent audio channel.",
+	}, []string{"source_name"})
+	prometheus.MustRegister(muted)
+	defer prometheus.Unregister(muted)
+	muted.WithLabelValues("Mic").Set(math.Inf(-1))
+
+	srv := httptest.NewServer(http.HandlerFunc(metricsJSONHandler))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var samples []struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"`
+		Value  interface{}       `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&samples); err != nil {
+		t.Fatalf("/metrics.json isn't valid JSON: %v", err)
+	}
+	for _, s := range samples {
+		if s.Name != "obs_test_channel_magnitude" {
+			continue
+		}
+		if s.Labels["source_name"] != "Mic" {
+			t.Errorf("labels = %v, want source_name=Mic", s.Labels)
+		}
+		if s.Value != "-Inf" {
+			t.Errorf("value = %#v, want \"-Inf\"", s.Value)
+		}
+		return
+	}
+	t.Errorf("obs_test_channel_magnitude not in /metrics.json: %+v", samples)
+}
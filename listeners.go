@@ -0,0 +1,108 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// ListenerConfig is one of the addresses to serve metrics on. Every listener serves the same metrics.
+type ListenerConfig struct {
+	// Address to listen on; empty listens on all addresses.
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+
+	// Username and Password, if set, require HTTP basic authentication.
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	TLSCertFile     string `json:"tls_cert_file"`
+	TLSKeyFile      string `json:"tls_key_file"`
+	TLSClientCAFile string `json:"tls_client_ca_file"`
+}
+
+// parseListeners parses a JSON list of listeners.
+func parseListeners(s string) ([]ListenerConfig, error) {
+	var listeners []ListenerConfig
+	if err := json.Unmarshal([]byte(s), &listeners); err != nil {
+		return nil, err
+	}
+	for n, l := range listeners {
+		if l.Port < 0 || l.Port > 65535 {
+			return nil, fmt.Errorf("listener %d: invalid port %d", n, l.Port)
+		}
+		if (l.Username == "") != (l.Password == "") {
+			return nil, fmt.Errorf("listener %d: username and password must be set together", n)
+		}
+		if (l.TLSCertFile == "") != (l.TLSKeyFile == "") {
+			return nil, fmt.Errorf("listener %d: TLS certificate and key must be set together", n)
+		}
+		if l.TLSClientCAFile != "" && l.TLSCertFile == "" {
+			return nil, fmt.Errorf("listener %d: a TLS client CA needs a TLS certificate", n)
+		}
+	}
+	return listeners, nil
+}
+
+// startListener binds and starts serving a configured listener.
+func startListener(l ListenerConfig) {
+	addr := net.JoinHostPort(l.Address, strconv.Itoa(l.Port))
+	var tlsConfig *tls.Config
+	if l.TLSCertFile != "" {
+		var err error
+		tlsConfig, err = loadTLSConfig(l.TLSCertFile, l.TLSKeyFile, l.TLSClientCAFile)
+		if err != nil {
+			// Don't fall back to serving plain HTTP if HTTPS was asked for.
+			countError(errorConfigParse)
+			slog.Error("failed to load TLS config, not serving HTTP", "address", addr, "err", err)
+			return
+		}
+	}
+	var handler http.Handler = serveMux
+	if l.Username != "" {
+		handler = basicAuth(l.Username, l.Password, handler)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		// Don't crash OBS because we couldn't listen on the port.
+		countError(errorPortBind)
+		slog.Error("net.Listen failed", "address", addr, "err", err)
+		return
+	}
+	serveListener(ln, tlsConfig, handler)
+}
+
+// basicAuth requires requests to next to use HTTP basic authentication with the given credentials.
+func basicAuth(username, password string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(u), []byte(username)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+		if !ok || !userOK || !passOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="obs-studio-exporter"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freePort returns a port that was free a moment ago.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestListenersServeMetrics(t *testing.T) {
+	defer func(mux *http.ServeMux) { serveMux = mux }(serveMux)
+	serveMux = newServeMux()
+
+	debug := ListenerConfig{Address: "127.0.0.1", Port: freePort(t)}
+	lan := ListenerConfig{Address: "127.0.0.1", Port: freePort(t), Username: "prometheus", Password: "hunter2"}
+	startListener(debug)
+	startListener(lan)
+	defer shutdownServers(time.Second)
+
+	for _, l := range []ListenerConfig{debug, lan} {
+		req, err := http.NewRequest("GET", "http://"+net.JoinHostPort(l.Address, strconv.Itoa(l.Port))+"/metrics", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.Username != "" {
+			req.SetBasicAuth(l.Username, l.Password)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET /metrics on port %d: %v", l.Port, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET /metrics on port %d = %d, want %d", l.Port, resp.StatusCode, http.StatusOK)
+		}
+		if !strings.Contains(string(body), "go_goroutines") {
+			t.Errorf("GET /metrics on port %d didn't return the registry's metrics:\n%s", l.Port, body)
+		}
+	}
+}
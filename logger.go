@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 	"unsafe"
 )
 
@@ -58,6 +59,9 @@ func (h *OBSHandler) Handle(ctx context.Context, r slog.Record) error {
 	if len(h.attrs) > 0 {
 		prefix = strings.Join(h.attrs, " ")
 	}
+	if logTail != nil {
+		logTail.add(h.logTailLine(r))
+	}
 	prefixStr := C.CString(prefix)
 	messageStr := C.CString(r.Message)
 	C.blogit(obsLevel, prefixStr, messageStr)
@@ -66,15 +70,31 @@ func (h *OBSHandler) Handle(ctx context.Context, r slog.Record) error {
 	return nil
 }
 
-func (h *OBSHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	newAttrs := make([]string, len(h.attrs), len(h.attrs)+len(attrs))
-	copy(newAttrs, h.attrs)
+// logTailLine formats a record for /logtail, with the handler's attributes followed by the message and then the record's.
+func (h *OBSHandler) logTailLine(r slog.Record) string {
+	fields := []string{r.Time.Format(time.RFC3339), r.Level.String()}
+	fields = append(fields, h.attrs...)
+	fields = append(fields, r.Message)
+	r.Attrs(func(attr slog.Attr) bool {
+		fields = append(fields, h.formatAttr(attr))
+		return true
+	})
+	return strings.Join(fields, " ")
+}
+
+func (h *OBSHandler) formatAttr(attr slog.Attr) string {
 	var groupPrefix string
 	if len(h.groups) > 0 {
 		groupPrefix = strings.Join(h.groups, ".") + "."
 	}
+	return fmt.Sprintf("%s%s=%s", groupPrefix, attr.Key, attr.Value.Resolve())
+}
+
+func (h *OBSHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	newAttrs := make([]string, len(h.attrs), len(h.attrs)+len(attrs))
+	copy(newAttrs, h.attrs)
 	for _, attr := range attrs {
-		newAttrs = append(newAttrs, fmt.Sprintf("%s%s=%s", groupPrefix, attr.Key, attr.Value.Resolve()))
+		newAttrs = append(newAttrs, h.formatAttr(attr))
 	}
 	return &OBSHandler{
 		attrs:  newAttrs,
@@ -0,0 +1,81 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogRingKeepsNewestLines(t *testing.T) {
+	r := newLogRing(3)
+	if got := r.tail(); len(got) != 0 {
+		t.Errorf("tail of an empty ring = %q", got)
+	}
+	for _, line := range []string{"one", "two", "three", "four", "five"} {
+		r.add(line)
+	}
+	if got, want := r.tail(), []string{"three", "four", "five"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tail = %q, want %q", got, want)
+	}
+}
+
+func TestLogTailHandlerServesLinesInOrder(t *testing.T) {
+	defer func(r *logRing) { logTail = r }(logTail)
+	logTail = newLogRing(10)
+
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := (&OBSHandler{}).WithAttrs([]slog.Attr{slog.String("listener", "127.0.0.1:9407")}).WithGroup("push").(*OBSHandler)
+	for _, rec := range []struct {
+		level slog.Level
+		msg   string
+		attrs []slog.Attr
+	}{
+		{slog.LevelInfo, "started", nil},
+		{slog.LevelWarn, "push failed", []slog.Attr{slog.Any("err", errors.New("connection refused")), slog.Int("attempt", 2)}},
+		{slog.LevelInfo, "stopped", nil},
+	} {
+		r := slog.NewRecord(at, rec.level, rec.msg, 0)
+		r.AddAttrs(rec.attrs...)
+		logTail.add(h.logTailLine(r))
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(logTailHandler))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"2024-03-01T12:00:00Z INFO listener=127.0.0.1:9407 started",
+		"2024-03-01T12:00:00Z WARN listener=127.0.0.1:9407 push failed push.err=connection refused push.attempt=2",
+		"2024-03-01T12:00:00Z INFO listener=127.0.0.1:9407 stopped",
+	}
+	if got := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("/logtail returned\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -35,15 +35,19 @@ void mc_volmeter_updated(void*, const float[MAX_AUDIO_CHANNELS], const float[MAX
 import "C"
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"math"
-	"net/http"
+	"net"
+	"runtime"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
@@ -55,20 +59,41 @@ var (
 const (
 	// number chosen by fair dice roll
 	circBufSamples = 32
+	// Peaks at or above full scale are counted as clipping.
+	clippingThresholdDBFS = 0
+	// Ports tried in turn if no port is configured.
+	firstScanPort = 9407
+	lastScanPort  = 9499
 	// Prometheus metrics namespace.
 	namespace = "obs"
 	// Prometheus metric subsystems
-	encoderSubsystem = "encoder"
-	globalSubsystem  = "global"
-	outputSubsystem  = "output"
-	sourceSubsystem  = "source"
+	audioSubsystem     = "audio"
+	encoderSubsystem   = "encoder"
+	exporterSubsystem  = "exporter"
+	filtersSubsystem   = "filters"
+	frontendSubsystem  = "frontend"
+	globalSubsystem    = "global"
+	groupSubsystem     = "group"
+	memorySubsystem    = "memory"
+	outputSubsystem    = "output"
+	profileSubsystem   = "profile"
+	sceneSubsystem     = "scene"
+	streamSubsystem    = "stream"
+	sourceSubsystem    = "source"
+	videoSubsystem     = "video"
+	websocketSubsystem = "websocket"
 )
 
 type Source struct {
-	ID       string
-	CID      *C.char
+	// ID is the source's type, e.g. wasapi_input_capture, which many sources can share. Sources
+	// are told apart by UUID, which CID holds a copy of for OBS's callbacks.
+	ID   string
+	UUID string
+	CID  *C.char
+	// Name is refreshed every scrape, since sources can be renamed. It's guarded by MetricCollector.mu.
 	Name     string
 	VolMeter *C.obs_volmeter_t
+	Weak     *C.obs_weak_source_t
 	Channels int
 
 	mu        sync.Mutex
@@ -76,167 +101,693 @@ type Source struct {
 	Magnitude [][circBufSamples]float64
 	Peak      [][circBufSamples]float64
 	InputPeak [][circBufSamples]float64
+	// SampleTimes is when each entry in the circular buffers was written.
+	SampleTimes [circBufSamples]time.Time
+	Clipping    []uint64
+	// SessionPeak is the highest peak of each channel since the source was first seen.
+	SessionPeak []float64
+	PeakHold    []peakHold
+
+	VolumeChanges uint64
+	// VolMeterUpdates counts volmeter callbacks, so a stalled audio pipeline shows up as a flat rate.
+	VolMeterUpdates uint64
 }
 
 type MetricCollector struct {
+	Up *prometheus.Desc
+
 	ActiveFPS          *prometheus.Desc
+	FPSRatio           *prometheus.Desc
+	BaseWidth          *prometheus.Desc
+	BaseHeight         *prometheus.Desc
+	ColorspaceInfo     *prometheus.Desc
 	AverageFrameTimeNS *prometheus.Desc
 	TotalFrames        *prometheus.Desc
 	LaggedFrames       *prometheus.Desc
 	VideoTotalFrames   *prometheus.Desc
 	VideoSkippedFrames *prometheus.Desc
+	RenderLagPercent   *prometheus.Desc
+	EncodeLagPercent   *prometheus.Desc
+	StreamHealthScore  *prometheus.Desc
+	SafeMode           *prometheus.Desc
+	OutputModeInfo     *prometheus.Desc
+	ProgramScene       *prometheus.Desc
+	ProgramWidth       *prometheus.Desc
+	ProgramHeight      *prometheus.Desc
+	PreviewWidth       *prometheus.Desc
+	PreviewHeight      *prometheus.Desc
+	StreamingActive    *prometheus.Desc
+	RecordingActive    *prometheus.Desc
+	RecordingPaused    *prometheus.Desc
+	PreviewScene       *prometheus.Desc
+	ReplayBufferLength *prometheus.Desc
+	PortableMode       *prometheus.Desc
+	MemoryAllocations  *prometheus.Desc
+	ActiveFilters      *prometheus.Desc
+
+	AudioMonitoringDeviceInfo *prometheus.Desc
+	AudioSampleRate           *prometheus.Desc
+	AudioSpeakers             *prometheus.Desc
+
+	InfoPerOutput                 *prometheus.Desc
+	KindPerOutput                 *prometheus.Desc
+	ServerHostPerOutput           *prometheus.Desc
+	OutputActivePerOutput         *prometheus.Desc
+	TotalBytesPerOutput           *prometheus.Desc
+	DroppedFramesPerOutput        *prometheus.Desc
+	DroppedFramesRatioPerOutput   *prometheus.Desc
+	TotalDroppedFrames            *prometheus.Desc
+	TotalFramesPerOutput          *prometheus.Desc
+	WidthPerOutput                *prometheus.Desc
+	HeightPerOutput               *prometheus.Desc
+	VideoSkippedFramesPerOutput   *prometheus.Desc
+	RescalePerOutput              *prometheus.Desc
+	CongestionPerOutput           *prometheus.Desc
+	ConnectTimePerOutput          *prometheus.Desc
+	ReconnectingPerOutput         *prometheus.Desc
+	ReconnectDelayPerOutput       *prometheus.Desc
+	SessionDroppedFramesPerOutput *prometheus.Desc
+	NetworkDroppedFramesPerOutput *prometheus.Desc
+	HasVideoEncoderPerOutput      *prometheus.Desc
+	HasAudioEncoderPerOutput      *prometheus.Desc
+	VideoBitratePerOutput         *prometheus.Desc
+	DynamicBitratePerOutput       *prometheus.Desc
+	CurrentBitratePerOutput       *prometheus.Desc
+	AudioBitratePerOutput         *prometheus.Desc
+
+	InfoPerEncoder           *prometheus.Desc
+	CodecPerEncoder          *prometheus.Desc
+	WidthPerEncoder          *prometheus.Desc
+	HeightPerEncoder         *prometheus.Desc
+	SampleRatePerEncoder     *prometheus.Desc
+	ActivePerEncoder         *prometheus.Desc
+	PresetPerEncoder         *prometheus.Desc
+	CPUUsagePerEncoder       *prometheus.Desc
+	InstancesPerEncoder      *prometheus.Desc
+	FPSDivisorPerEncoder     *prometheus.Desc
+	GPUIndexPerEncoder       *prometheus.Desc
+	ReconfigurablePerEncoder *prometheus.Desc
+	OutputDropsPerEncoder    *prometheus.Desc
+
+	MagnitudePerSourceChannel   *prometheus.Desc
+	PeakPerSourceChannel        *prometheus.Desc
+	InputPeakPerSourceChannel   *prometheus.Desc
+	ClippingPerSourceChannel    *prometheus.Desc
+	SessionPeakPerSourceChannel *prometheus.Desc
+	PeakHoldPerSourceChannel    *prometheus.Desc
+	BalancePerSource            *prometheus.Desc
+	LatencyPerSource            *prometheus.Desc
+	PushToTalkPerSource         *prometheus.Desc
+	PushToMutePerSource         *prometheus.Desc
+	VolumeChangesPerSource      *prometheus.Desc
+	VolMeterUpdatesPerSource    *prometheus.Desc
+	AudioMixersPerSource        *prometheus.Desc
+	AudioTrackPerSource         *prometheus.Desc
+	GlobalChannelPerSource      *prometheus.Desc
+	AudioFilterParamPerSource   *prometheus.Desc
+	WidthPerSource              *prometheus.Desc
+	HeightPerSource             *prometheus.Desc
+	FrozenPerSource             *prometheus.Desc
+	SettingsHashPerSource       *prometheus.Desc
+	CaptureTargetPerSource      *prometheus.Desc
 
-	InfoPerOutput          *prometheus.Desc
-	OutputActivePerOutput  *prometheus.Desc
-	TotalBytesPerOutput    *prometheus.Desc
-	DroppedFramesPerOutput *prometheus.Desc
-	TotalFramesPerOutput   *prometheus.Desc
-	WidthPerOutput         *prometheus.Desc
-	HeightPerOutput        *prometheus.Desc
-	CongestionPerOutput    *prometheus.Desc
-	ConnectTimePerOutput   *prometheus.Desc
-	ReconnectingPerOutput  *prometheus.Desc
-
-	InfoPerEncoder       *prometheus.Desc
-	CodecPerEncoder      *prometheus.Desc
-	WidthPerEncoder      *prometheus.Desc
-	HeightPerEncoder     *prometheus.Desc
-	SampleRatePerEncoder *prometheus.Desc
-	ActivePerEncoder     *prometheus.Desc
-
-	MagnitudePerSourceChannel *prometheus.Desc
-	PeakPerSourceChannel      *prometheus.Desc
-	InputPeakPerSourceChannel *prometheus.Desc
-
-	mu      sync.Mutex
+	MissingSourcesPerScene *prometheus.Desc
+	InfoPerScene           *prometheus.Desc
+	ActivePerScene         *prometheus.Desc
+	PreviewPerScene        *prometheus.Desc
+	ItemCountPerScene      *prometheus.Desc
+	IsGroupPerSource       *prometheus.Desc
+	MembersPerGroup        *prometheus.Desc
+	SceneRefsPerSource     *prometheus.Desc
+
+	EncoderInfoPerProfile *prometheus.Desc
+
+	WebSocketEnabled *prometheus.Desc
+
+	SourcesTruncated *prometheus.Desc
+	AudioBufferBytes *prometheus.Desc
+	BuildInfo        *prometheus.Desc
+
+	// describedMetrics is the config's EnabledMetrics when the collector was made.
+	describedMetrics map[string]bool
+	// sourcesEnumerated is set once a scrape has enumerated OBS's sources.
+	sourcesEnumerated atomic.Bool
+
+	mu sync.Mutex
+	// sources is keyed by UUID.
 	sources map[string]*Source
 
+	// outputs, encoderDrops and encoderCPU are guarded by obsLock.
+	outputs map[string]*outputState
+	// encoderDrops is the number of frames dropped by the outputs each video encoder feeds,
+	// as of the last snapshot of the outputs.
+	encoderDrops encoderDrops
+	encoderCPU   encoderCPUSampler
+
 	enumSourcesCB  func(unsafe.Pointer, *C.obs_source_t) C.bool
 	enumOutputsCB  func(unsafe.Pointer, *C.obs_output_t) C.bool
 	enumEncodersCB func(unsafe.Pointer, *C.obs_encoder_t) C.bool
+
+	enumScenesCB     func(unsafe.Pointer, *C.obs_source_t) C.bool
+	enumSceneItemsCB func(*C.obs_scene_t, *C.obs_sceneitem_t, unsafe.Pointer) C.bool
+	enumFiltersCB    func(parent, child *C.obs_source_t, v unsafe.Pointer)
+}
+
+// newDesc is prometheus.NewDesc, but uses the configured help text for the metric if there is one.
+func newDesc(fqName, help string, variableLabels []string, constLabels prometheus.Labels) *prometheus.Desc {
+	if h := activeConfig.HelpOverrides[fqName]; h != "" {
+		help = h
+	}
+	d := prometheus.NewDesc(fqName, help, variableLabels, constLabels)
+	descNames[d] = fqName
+	return d
 }
 
 func NewMetricCollector() *MetricCollector {
+	channelLabels := []string{"source_id", "source_name", "channel_id"}
+	if activeConfig.CombineChannels {
+		channelLabels = []string{"source_id", "source_name"}
+	}
 	return &MetricCollector{
-		ActiveFPS: prometheus.NewDesc(
+		Up: newDesc(
+			prometheus.BuildFQName(namespace, "", "up"),
+			"Whether OBS metrics are being collected; 0 once OBS has started shutting down.",
+			nil, prometheus.Labels{},
+		),
+
+		ActiveFPS: newDesc(
 			prometheus.BuildFQName(namespace, globalSubsystem, "active_fps"),
 			"Active frames per second.",
 			nil, prometheus.Labels{},
 		),
-		AverageFrameTimeNS: prometheus.NewDesc(
+		FPSRatio: newDesc(
+			prometheus.BuildFQName(namespace, globalSubsystem, "fps_ratio"),
+			"Active frames per second as a fraction of the configured frames per second.",
+			nil, prometheus.Labels{},
+		),
+		BaseWidth: newDesc(
+			prometheus.BuildFQName(namespace, globalSubsystem, "base_width"),
+			"Width of the canvas scenes are rendered to, in pixels.",
+			nil, prometheus.Labels{},
+		),
+		BaseHeight: newDesc(
+			prometheus.BuildFQName(namespace, globalSubsystem, "base_height"),
+			"Height of the canvas scenes are rendered to, in pixels.",
+			nil, prometheus.Labels{},
+		),
+		ColorspaceInfo: newDesc(
+			prometheus.BuildFQName(namespace, videoSubsystem, "colorspace_info"),
+			"The color space and range video is rendered in, and the brightness of SDR white in nits.",
+			[]string{"colorspace", "range", "sdr_white_nits"}, prometheus.Labels{},
+		),
+		AverageFrameTimeNS: newDesc(
 			prometheus.BuildFQName(namespace, globalSubsystem, "average_frame_time_ns"),
 			"Average time to render a frame in nanoseconds.",
 			nil, prometheus.Labels{},
 		),
-		TotalFrames: prometheus.NewDesc(
+		TotalFrames: newDesc(
 			prometheus.BuildFQName(namespace, globalSubsystem, "frames_total"),
 			"Total frames generated.",
 			nil, prometheus.Labels{},
 		),
-		LaggedFrames: prometheus.NewDesc(
+		LaggedFrames: newDesc(
 			prometheus.BuildFQName(namespace, globalSubsystem, "lagged_frames_total"),
 			"Skipped frames due to encoding lag.",
 			nil, prometheus.Labels{},
 		),
-		VideoTotalFrames: prometheus.NewDesc(
+		VideoTotalFrames: newDesc(
 			prometheus.BuildFQName(namespace, globalSubsystem, "video_frames_total"),
 			"Total video frames generated.",
 			nil, prometheus.Labels{},
 		),
-		VideoSkippedFrames: prometheus.NewDesc(
+		VideoSkippedFrames: newDesc(
 			prometheus.BuildFQName(namespace, globalSubsystem, "video_skipped_frames_total"),
 			"Frames missed due to rendering lab.",
 			nil, prometheus.Labels{},
 		),
+		RenderLagPercent: newDesc(
+			prometheus.BuildFQName(namespace, globalSubsystem, "render_lag_percent"),
+			"Percentage of frames missed due to rendering lag, as shown in the stats dock.",
+			nil, prometheus.Labels{},
+		),
+		EncodeLagPercent: newDesc(
+			prometheus.BuildFQName(namespace, globalSubsystem, "encode_lag_percent"),
+			"Percentage of frames skipped due to encoding lag, as shown in the stats dock.",
+			nil, prometheus.Labels{},
+		),
+		StreamHealthScore: newDesc(
+			prometheus.BuildFQName(namespace, streamSubsystem, "health_score"),
+			"Stream health from 0 to 100, combining congestion, dropped frames and render and encode lag.",
+			nil, prometheus.Labels{},
+		),
+		SafeMode: newDesc(
+			prometheus.BuildFQName(namespace, frontendSubsystem, "safe_mode"),
+			"Whether OBS was started in safe mode. OBS doesn't load plugins like this one in safe mode, so it's always 0.",
+			nil, prometheus.Labels{},
+		),
+		OutputModeInfo: newDesc(
+			prometheus.BuildFQName(namespace, frontendSubsystem, "output_mode_info"),
+			"Whether the current profile uses Simple or Advanced output settings.",
+			[]string{"mode"}, prometheus.Labels{},
+		),
+		StreamingActive: newDesc(
+			prometheus.BuildFQName(namespace, frontendSubsystem, "streaming_active"),
+			"Whether OBS is streaming.",
+			nil, prometheus.Labels{},
+		),
+		RecordingActive: newDesc(
+			prometheus.BuildFQName(namespace, frontendSubsystem, "recording_active"),
+			"Whether OBS is recording.",
+			nil, prometheus.Labels{},
+		),
+		RecordingPaused: newDesc(
+			prometheus.BuildFQName(namespace, frontendSubsystem, "recording_paused"),
+			"Whether the recording is paused.",
+			nil, prometheus.Labels{},
+		),
+		ProgramScene: newDesc(
+			prometheus.BuildFQName(namespace, frontendSubsystem, "current_program_scene"),
+			"The scene being output.",
+			[]string{"scene_name"}, prometheus.Labels{},
+		),
+		PreviewScene: newDesc(
+			prometheus.BuildFQName(namespace, frontendSubsystem, "current_preview_scene"),
+			"The scene in studio mode's preview.",
+			[]string{"scene_name"}, prometheus.Labels{},
+		),
+		ProgramWidth: newDesc(
+			prometheus.BuildFQName(namespace, frontendSubsystem, "program_width"),
+			"Width of the program scene in studio mode.",
+			nil, prometheus.Labels{},
+		),
+		ProgramHeight: newDesc(
+			prometheus.BuildFQName(namespace, frontendSubsystem, "program_height"),
+			"Height of the program scene in studio mode.",
+			nil, prometheus.Labels{},
+		),
+		PreviewWidth: newDesc(
+			prometheus.BuildFQName(namespace, frontendSubsystem, "preview_width"),
+			"Width of the preview scene in studio mode.",
+			nil, prometheus.Labels{},
+		),
+		PreviewHeight: newDesc(
+			prometheus.BuildFQName(namespace, frontendSubsystem, "preview_height"),
+			"Height of the preview scene in studio mode.",
+			nil, prometheus.Labels{},
+		),
+		ReplayBufferLength: newDesc(
+			prometheus.BuildFQName(namespace, frontendSubsystem, "replay_buffer_length_seconds"),
+			"Maximum replay buffer length configured in the current profile.",
+			nil, prometheus.Labels{},
+		),
+		PortableMode: newDesc(
+			prometheus.BuildFQName(namespace, "", "portable_mode"),
+			"Whether OBS is running in portable mode.",
+			nil, prometheus.Labels{},
+		),
+		MemoryAllocations: newDesc(
+			prometheus.BuildFQName(namespace, memorySubsystem, "allocations"),
+			"Outstanding memory allocations made by OBS through bmalloc.",
+			nil, prometheus.Labels{},
+		),
+		ActiveFilters: newDesc(
+			prometheus.BuildFQName(namespace, filtersSubsystem, "active_total"),
+			"Number of enabled filters across all sources and scenes.",
+			nil, prometheus.Labels{},
+		),
 
-		InfoPerOutput: prometheus.NewDesc(
+		AudioMonitoringDeviceInfo: newDesc(
+			prometheus.BuildFQName(namespace, audioSubsystem, "monitoring_device_info"),
+			"The device monitored audio is played on.",
+			[]string{"name", "id"}, prometheus.Labels{},
+		),
+		AudioSampleRate: newDesc(
+			prometheus.BuildFQName(namespace, audioSubsystem, "sample_rate_hertz"),
+			"Sample rate OBS mixes audio at.",
+			nil, prometheus.Labels{},
+		),
+		AudioSpeakers: newDesc(
+			prometheus.BuildFQName(namespace, audioSubsystem, "speakers"),
+			"Number of speaker channels OBS mixes audio to.",
+			nil, prometheus.Labels{},
+		),
+
+		InfoPerOutput: newDesc(
 			prometheus.BuildFQName(namespace, outputSubsystem, "info"),
 			"Information about this output.",
 			[]string{"output_id", "output_name", "output_display_name"}, prometheus.Labels{},
 		),
-		OutputActivePerOutput: prometheus.NewDesc(
+		KindPerOutput: newDesc(
+			prometheus.BuildFQName(namespace, outputSubsystem, "kind"),
+			"What this output is used for: streaming, recording, virtualcam, replay_buffer or other.",
+			[]string{"output_id", "output_name", "kind"}, prometheus.Labels{},
+		),
+		ServerHostPerOutput: newDesc(
+			prometheus.BuildFQName(namespace, outputSubsystem, "server_host_info"),
+			"Host of the server this streaming output is sending to.",
+			[]string{"output_id", "output_name", "host"}, prometheus.Labels{},
+		),
+		OutputActivePerOutput: newDesc(
 			prometheus.BuildFQName(namespace, outputSubsystem, "active"),
 			"Whether the output is active.",
 			[]string{"output_id", "output_name"}, prometheus.Labels{},
 		),
-		TotalBytesPerOutput: prometheus.NewDesc(
+		TotalBytesPerOutput: newDesc(
 			prometheus.BuildFQName(namespace, outputSubsystem, "bytes_total"),
 			"Total bytes sent to this output.", []string{"output_id", "output_name"}, prometheus.Labels{},
 		),
-		DroppedFramesPerOutput: prometheus.NewDesc(
+		DroppedFramesPerOutput: newDesc(
 			prometheus.BuildFQName(namespace, outputSubsystem, "dropped_frames_total"),
 			"Frames dropped by this output.", []string{"output_id", "output_name"}, prometheus.Labels{},
 		),
-		TotalFramesPerOutput: prometheus.NewDesc(
+		DroppedFramesRatioPerOutput: newDesc(
+			prometheus.BuildFQName(namespace, outputSubsystem, "dropped_frames_ratio"),
+			"Fraction of this output's frames that were dropped.", []string{"output_id", "output_name"}, prometheus.Labels{},
+		),
+		TotalDroppedFrames: newDesc(
+			prometheus.BuildFQName(namespace, "outputs", "total_dropped_frames"),
+			"Frames dropped by all current outputs.", nil, prometheus.Labels{},
+		),
+		TotalFramesPerOutput: newDesc(
 			prometheus.BuildFQName(namespace, outputSubsystem, "frames"),
 			"Total frames sent from this output.", []string{"output_id", "output_name"}, prometheus.Labels{},
 		),
-		WidthPerOutput: prometheus.NewDesc(
+		WidthPerOutput: newDesc(
 			prometheus.BuildFQName(namespace, outputSubsystem, "video_width"),
 			"Video width of this output.", []string{"output_id", "output_name"}, prometheus.Labels{},
 		),
-		HeightPerOutput: prometheus.NewDesc(
+		HeightPerOutput: newDesc(
 			prometheus.BuildFQName(namespace, outputSubsystem, "video_height"),
 			"Video height of this output.", []string{"output_id", "output_name"}, prometheus.Labels{},
 		),
-		CongestionPerOutput: prometheus.NewDesc(
+		VideoSkippedFramesPerOutput: newDesc(
+			prometheus.BuildFQName(namespace, outputSubsystem, "video_skipped_frames_total"),
+			"Frames skipped by the video pipeline feeding this output.", []string{"output_id", "output_name"}, prometheus.Labels{},
+		),
+		RescalePerOutput: newDesc(
+			prometheus.BuildFQName(namespace, outputSubsystem, "rescale_info"),
+			"Present if this output is rescaled from the canvas's output resolution, labelled with the scale filter.",
+			[]string{"output_id", "output_name", "scale_type"}, prometheus.Labels{},
+		),
+		CongestionPerOutput: newDesc(
 			prometheus.BuildFQName(namespace, outputSubsystem, "congestion"),
 			"'Congestion' of this output.",
 			[]string{"output_id", "output_name"}, prometheus.Labels{},
 		),
-		ConnectTimePerOutput: prometheus.NewDesc(
+		ConnectTimePerOutput: newDesc(
 			prometheus.BuildFQName(namespace, outputSubsystem, "connect_time_seconds"),
 			"Time taken to connect in seconds for this output.",
 			[]string{"output_id", "output_name"}, prometheus.Labels{},
 		),
-		ReconnectingPerOutput: prometheus.NewDesc(
+		ReconnectingPerOutput: newDesc(
 			prometheus.BuildFQName(namespace, outputSubsystem, "reconnecting"),
 			"Whether the output is reconnecting.",
 			[]string{"output_id", "output_name"}, prometheus.Labels{},
 		),
+		ReconnectDelayPerOutput: newDesc(
+			prometheus.BuildFQName(namespace, outputSubsystem, "reconnect_delay_seconds_remaining"),
+			"Seconds until this reconnecting output next tries to connect.",
+			[]string{"output_id", "output_name"}, prometheus.Labels{},
+		),
+		SessionDroppedFramesPerOutput: newDesc(
+			prometheus.BuildFQName(namespace, outputSubsystem, "dropped_frames_session"),
+			"Frames dropped by this output since it last became active.",
+			[]string{"output_id", "output_name"}, prometheus.Labels{},
+		),
+		NetworkDroppedFramesPerOutput: newDesc(
+			prometheus.BuildFQName(namespace, outputSubsystem, "network_dropped_frames_total"),
+			"Frames dropped by this output while it was congested, as seen by the exporter.",
+			[]string{"output_id", "output_name"}, prometheus.Labels{},
+		),
+		HasVideoEncoderPerOutput: newDesc(
+			prometheus.BuildFQName(namespace, outputSubsystem, "has_video_encoder"),
+			"Whether this output has a video encoder attached.",
+			[]string{"output_id", "output_name"}, prometheus.Labels{},
+		),
+		HasAudioEncoderPerOutput: newDesc(
+			prometheus.BuildFQName(namespace, outputSubsystem, "has_audio_encoder"),
+			"Whether this output has at least one audio encoder attached.",
+			[]string{"output_id", "output_name"}, prometheus.Labels{},
+		),
+		VideoBitratePerOutput: newDesc(
+			prometheus.BuildFQName(namespace, outputSubsystem, "video_bitrate_kbps"),
+			"Estimated video bitrate of this output since the last scrape in kbps.",
+			[]string{"output_id", "output_name"}, prometheus.Labels{},
+		),
+		AudioBitratePerOutput: newDesc(
+			prometheus.BuildFQName(namespace, outputSubsystem, "audio_bitrate_kbps"),
+			"Estimated audio bitrate of this output since the last scrape in kbps.",
+			[]string{"output_id", "output_name"}, prometheus.Labels{},
+		),
+		DynamicBitratePerOutput: newDesc(
+			prometheus.BuildFQName(namespace, outputSubsystem, "dynamic_bitrate_enabled"),
+			"Whether this output adjusts its video bitrate to network conditions.",
+			[]string{"output_id", "output_name"}, prometheus.Labels{},
+		),
+		CurrentBitratePerOutput: newDesc(
+			prometheus.BuildFQName(namespace, outputSubsystem, "current_bitrate_kbps"),
+			"Bitrate this output's video encoder is currently set to in kbps, including dynamic bitrate adjustments.",
+			[]string{"output_id", "output_name"}, prometheus.Labels{},
+		),
 
-		InfoPerEncoder: prometheus.NewDesc(
+		InfoPerEncoder: newDesc(
 			prometheus.BuildFQName(namespace, encoderSubsystem, "info"),
 			"Information about this encoder.",
 			[]string{"encoder_id", "encoder_name", "encoder_display_name", "encoder_codec"}, prometheus.Labels{},
 		),
-		WidthPerEncoder: prometheus.NewDesc(
+		WidthPerEncoder: newDesc(
 			prometheus.BuildFQName(namespace, encoderSubsystem, "width"),
 			"Video width of this encoder.",
 			[]string{"encoder_id", "encoder_name"}, prometheus.Labels{},
 		),
-		HeightPerEncoder: prometheus.NewDesc(
+		HeightPerEncoder: newDesc(
 			prometheus.BuildFQName(namespace, encoderSubsystem, "height"),
 			"Video height of this encoder.",
 			[]string{"encoder_id", "encoder_name"}, prometheus.Labels{},
 		),
-		SampleRatePerEncoder: prometheus.NewDesc(
+		SampleRatePerEncoder: newDesc(
 			prometheus.BuildFQName(namespace, encoderSubsystem, "sample_rate"),
 			"Audio sample rate of this encoder.", []string{"encoder_id", "encoder_name"}, prometheus.Labels{},
 		),
-		ActivePerEncoder: prometheus.NewDesc(
+		ActivePerEncoder: newDesc(
 			prometheus.BuildFQName(namespace, globalSubsystem, "active"),
 			"Whether the encoder is active.",
 			[]string{"encoder_id", "encoder_name"}, prometheus.Labels{},
 		),
+		PresetPerEncoder: newDesc(
+			prometheus.BuildFQName(namespace, encoderSubsystem, "preset_info"),
+			"The preset this encoder is configured with.",
+			[]string{"encoder_id", "encoder_name", "preset"}, prometheus.Labels{},
+		),
+		FPSDivisorPerEncoder: newDesc(
+			prometheus.BuildFQName(namespace, encoderSubsystem, "fps_divisor"),
+			"Number of base video frames for each frame this encoder encodes.",
+			[]string{"encoder_id", "encoder_name"}, prometheus.Labels{},
+		),
+		GPUIndexPerEncoder: newDesc(
+			prometheus.BuildFQName(namespace, encoderSubsystem, "gpu_index"),
+			"Index of the GPU this encoder is configured to use, or -1 if it doesn't select one.",
+			[]string{"encoder_id", "encoder_name"}, prometheus.Labels{},
+		),
+		ReconfigurablePerEncoder: newDesc(
+			prometheus.BuildFQName(namespace, encoderSubsystem, "reconfigurable"),
+			"Whether this encoder supports changing its bitrate while it's encoding.",
+			[]string{"encoder_id", "encoder_name"}, prometheus.Labels{},
+		),
+		OutputDropsPerEncoder: newDesc(
+			prometheus.BuildFQName(namespace, encoderSubsystem, "output_dropped_frames_total"),
+			"Frames dropped by the outputs this video encoder feeds, summed across them.",
+			[]string{"encoder_id", "encoder_name"}, prometheus.Labels{},
+		),
+		CPUUsagePerEncoder: newDesc(
+			prometheus.BuildFQName(namespace, encoderSubsystem, "cpu_usage_percent"),
+			"Estimated CPU used by this software encoder since the last scrape, as a percentage of one core.",
+			[]string{"encoder_id", "encoder_name"}, prometheus.Labels{},
+		),
+		InstancesPerEncoder: newDesc(
+			prometheus.BuildFQName(namespace, encoderSubsystem, "instances"),
+			"Number of encoders with this encoder ID.",
+			[]string{"encoder_id"}, prometheus.Labels{},
+		),
 
-		MagnitudePerSourceChannel: prometheus.NewDesc(
+		MagnitudePerSourceChannel: newDesc(
 			prometheus.BuildFQName(namespace, sourceSubsystem, "channel_magnitude"),
 			"Max source channel magnitude.",
-			[]string{"source_id", "source_name", "channel_id"}, prometheus.Labels{},
+			channelLabels, prometheus.Labels{},
 		),
-		PeakPerSourceChannel: prometheus.NewDesc(
+		PeakPerSourceChannel: newDesc(
 			prometheus.BuildFQName(namespace, sourceSubsystem, "channel_peak"),
 			"Max source channel peak.",
-			[]string{"source_id", "source_name", "channel_id"}, prometheus.Labels{},
+			channelLabels, prometheus.Labels{},
 		),
-		InputPeakPerSourceChannel: prometheus.NewDesc(
+		InputPeakPerSourceChannel: newDesc(
 			prometheus.BuildFQName(namespace, sourceSubsystem, "input_peak"),
 			"Max source channel input peak.",
-			[]string{"source_id", "source_name", "channel_id"}, prometheus.Labels{},
+			channelLabels, prometheus.Labels{},
+		),
+		ClippingPerSourceChannel: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "channel_clipping_total"),
+			"Volume meter updates in which this source channel's peak reached 0 dBFS.",
+			channelLabels, prometheus.Labels{},
+		),
+		SessionPeakPerSourceChannel: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "channel_session_peak"),
+			"Highest peak of this source channel since the exporter first saw the source.",
+			channelLabels, prometheus.Labels{},
+		),
+		PeakHoldPerSourceChannel: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "channel_peak_hold"),
+			"Recent highest peak of this source channel, held and then decayed like OBS's volume meters.",
+			channelLabels, prometheus.Labels{},
+		),
+		BalancePerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "balance"),
+			"Stereo balance of this audio source, from 0 (left) to 1 (right); 0.5 is centered.",
+			[]string{"source_id", "source_name"}, prometheus.Labels{},
+		),
+		LatencyPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "latency_ns"),
+			"Estimated delay OBS adds to this audio source in nanoseconds; currently its sync offset.",
+			[]string{"source_id", "source_name"}, prometheus.Labels{},
+		),
+		PushToTalkPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "push_to_talk_enabled"),
+			"Whether push-to-talk is turned on for this audio source, so it's muted unless the hotkey is held.",
+			[]string{"source_id", "source_name"}, prometheus.Labels{},
+		),
+		PushToMutePerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "push_to_mute_enabled"),
+			"Whether push-to-mute is turned on for this audio source, so it's muted while the hotkey is held.",
+			[]string{"source_id", "source_name"}, prometheus.Labels{},
+		),
+		AudioMixersPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "audio_mixers"),
+			"Bitmask of the audio tracks this source is routed to; bit 0 is track 1.",
+			[]string{"source_id", "source_name"}, prometheus.Labels{},
+		),
+		AudioTrackPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "audio_track_enabled"),
+			"Whether this source is routed to this audio track.",
+			[]string{"source_id", "source_name", "track"}, prometheus.Labels{},
+		),
+		VolumeChangesPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "volume_changes_total"),
+			"Times this source's volume has been changed.",
+			[]string{"source_id", "source_name"}, prometheus.Labels{},
+		),
+		VolMeterUpdatesPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "volmeter_updates_total"),
+			"Times OBS has reported audio levels for this source.",
+			[]string{"source_id", "source_name"}, prometheus.Labels{},
+		),
+		WidthPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "video_width"),
+			"Width of this video source.",
+			[]string{"source_id", "source_name"}, prometheus.Labels{},
+		),
+		HeightPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "video_height"),
+			"Height of this video source.",
+			[]string{"source_id", "source_name"}, prometheus.Labels{},
+		),
+		FrozenPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "frozen"),
+			"Whether this video source is being shown but has no size, which usually means its capture has died.",
+			[]string{"source_id", "source_name"}, prometheus.Labels{},
+		),
+		AudioFilterParamPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "audio_filter_param"),
+			"Setting of a built-in audio filter on this source, such as a gain filter's gain in dB.",
+			[]string{"source_id", "source_name", "filter_id", "filter_name", "param"}, prometheus.Labels{},
+		),
+		GlobalChannelPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "global_channel"),
+			"Output channel this source is assigned to as a global audio device.",
+			[]string{"source_id", "source_name"}, prometheus.Labels{},
+		),
+		SettingsHashPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "settings_hash"),
+			"Hash of this source's settings; changes whenever the settings change.",
+			[]string{"source_id", "source_name"}, prometheus.Labels{},
+		),
+		CaptureTargetPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "capture_target_info"),
+			"What this capture source is capturing.",
+			[]string{"source_name", "target"}, prometheus.Labels{},
+		),
+
+		MissingSourcesPerScene: newDesc(
+			prometheus.BuildFQName(namespace, sceneSubsystem, "missing_sources_total"),
+			"Number of items in this scene whose source no longer exists.",
+			[]string{"scene_name"}, prometheus.Labels{},
+		),
+		InfoPerScene: newDesc(
+			prometheus.BuildFQName(namespace, sceneSubsystem, "info"),
+			"Information about this scene.",
+			[]string{"scene_name", "scene_uuid"}, prometheus.Labels{},
+		),
+		ActivePerScene: newDesc(
+			prometheus.BuildFQName(namespace, sceneSubsystem, "active"),
+			"Whether this is the program scene, which is being output.",
+			[]string{"scene_name"}, prometheus.Labels{},
+		),
+		PreviewPerScene: newDesc(
+			prometheus.BuildFQName(namespace, sceneSubsystem, "preview"),
+			"Whether this is the preview scene in studio mode.",
+			[]string{"scene_name"}, prometheus.Labels{},
+		),
+		ItemCountPerScene: newDesc(
+			prometheus.BuildFQName(namespace, sceneSubsystem, "item_count"),
+			"Number of items in this scene, counting each group as one item.",
+			[]string{"scene_name"}, prometheus.Labels{},
+		),
+		IsGroupPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "is_group"),
+			"Whether this scene or group source is a group.",
+			[]string{"source_name"}, prometheus.Labels{},
+		),
+		MembersPerGroup: newDesc(
+			prometheus.BuildFQName(namespace, groupSubsystem, "member_count"),
+			"Number of items directly inside this group.",
+			[]string{"group_name"}, prometheus.Labels{},
+		),
+		SceneRefsPerSource: newDesc(
+			prometheus.BuildFQName(namespace, sourceSubsystem, "scene_reference_count"),
+			"Number of scenes containing this source, including inside groups. 0 means the source isn't in any scene.",
+			[]string{"source_name"}, prometheus.Labels{},
+		),
+
+		EncoderInfoPerProfile: newDesc(
+			prometheus.BuildFQName(namespace, profileSubsystem, "encoder_info"),
+			"The streaming encoder configured in this profile, whether or not it's the current profile.",
+			[]string{"profile_name", "encoder_id", "bitrate"}, prometheus.Labels{},
+		),
+
+		WebSocketEnabled: newDesc(
+			prometheus.BuildFQName(namespace, websocketSubsystem, "enabled"),
+			"Whether the obs-websocket server is enabled. Only present if obs-websocket is loaded.",
+			nil, prometheus.Labels{},
+		),
+
+		SourcesTruncated: newDesc(
+			prometheus.BuildFQName(namespace, exporterSubsystem, "sources_truncated"),
+			"Whether there are more sources than the configured maximum, so some aren't being exported.",
+			nil, prometheus.Labels{},
+		),
+		AudioBufferBytes: newDesc(
+			prometheus.BuildFQName(namespace, exporterSubsystem, "audio_buffer_bytes"),
+			"Bytes allocated for the circular buffers of audio levels kept for each source channel.",
+			nil, prometheus.Labels{},
+		),
+		BuildInfo: newDesc(
+			prometheus.BuildFQName(namespace, exporterSubsystem, "build_info"),
+			"The value is irrelevant, but the labels describe how the exporter was built.",
+			[]string{"version", "go_version", "libobs_api_version"}, prometheus.Labels{},
 		),
 
-		sources: map[string]*Source{},
+		describedMetrics: activeConfig.EnabledMetrics,
+		sources:          map[string]*Source{},
+		outputs:          map[string]*outputState{},
 	}
 }
 
@@ -244,220 +795,1040 @@ func (c *MetricCollector) Describe(ch chan<- *prometheus.Desc) {
 	obsLock.Lock()
 	defer obsLock.Unlock()
 
-	ch <- c.ActiveFPS
-	ch <- c.AverageFrameTimeNS
-	ch <- c.TotalFrames
-	ch <- c.LaggedFrames
-	ch <- c.VideoTotalFrames
-	ch <- c.VideoSkippedFrames
-
-	ch <- c.OutputActivePerOutput
-	ch <- c.TotalBytesPerOutput
-	ch <- c.DroppedFramesPerOutput
-	ch <- c.TotalFramesPerOutput
-	ch <- c.WidthPerOutput
-	ch <- c.HeightPerOutput
-	ch <- c.CongestionPerOutput
-	ch <- c.ConnectTimePerOutput
-	ch <- c.ReconnectingPerOutput
-
-	ch <- c.InfoPerEncoder
-	ch <- c.WidthPerEncoder
-	ch <- c.HeightPerEncoder
-	ch <- c.SampleRatePerEncoder
-	ch <- c.ActivePerEncoder
-
-	ch <- c.MagnitudePerSourceChannel
-	ch <- c.PeakPerSourceChannel
-	ch <- c.InputPeakPerSourceChannel
-}
-
-func obsBoolMetric(b C.bool) float64 {
-	if bool(b) {
+	// This uses the metrics that were enabled when the collector was made, rather than the
+	// current config, since unregistering it must see the descriptors it was registered with.
+	// The registry isn't pedantic, so metrics turned on by a reload are still collected.
+	describe := func(d *prometheus.Desc) {
+		if metricEnabled(c.describedMetrics, descNames[d]) {
+			ch <- d
+		}
+	}
+
+	describe(c.Up)
+
+	describe(c.ActiveFPS)
+	describe(c.FPSRatio)
+	describe(c.BaseWidth)
+	describe(c.BaseHeight)
+	describe(c.ColorspaceInfo)
+	describe(c.AverageFrameTimeNS)
+	describe(c.TotalFrames)
+	describe(c.LaggedFrames)
+	describe(c.VideoTotalFrames)
+	describe(c.VideoSkippedFrames)
+	describe(c.RenderLagPercent)
+	describe(c.EncodeLagPercent)
+	describe(c.StreamHealthScore)
+	describe(c.SafeMode)
+	describe(c.OutputModeInfo)
+	describe(c.StreamingActive)
+	describe(c.RecordingActive)
+	describe(c.RecordingPaused)
+	describe(c.ProgramScene)
+	describe(c.PreviewScene)
+	describe(c.ProgramWidth)
+	describe(c.ProgramHeight)
+	describe(c.PreviewWidth)
+	describe(c.PreviewHeight)
+	describe(c.ReplayBufferLength)
+	describe(c.PortableMode)
+	describe(c.MemoryAllocations)
+	describe(c.ActiveFilters)
+
+	describe(c.AudioMonitoringDeviceInfo)
+	describe(c.AudioSampleRate)
+	describe(c.AudioSpeakers)
+
+	describe(c.KindPerOutput)
+	describe(c.ServerHostPerOutput)
+	describe(c.OutputActivePerOutput)
+	describe(c.TotalBytesPerOutput)
+	describe(c.DroppedFramesPerOutput)
+	describe(c.DroppedFramesRatioPerOutput)
+	describe(c.TotalDroppedFrames)
+	describe(c.TotalFramesPerOutput)
+	describe(c.WidthPerOutput)
+	describe(c.HeightPerOutput)
+	describe(c.VideoSkippedFramesPerOutput)
+	describe(c.RescalePerOutput)
+	describe(c.CongestionPerOutput)
+	describe(c.ConnectTimePerOutput)
+	describe(c.ReconnectingPerOutput)
+	describe(c.ReconnectDelayPerOutput)
+	describe(c.SessionDroppedFramesPerOutput)
+	describe(c.NetworkDroppedFramesPerOutput)
+	describe(c.HasVideoEncoderPerOutput)
+	describe(c.HasAudioEncoderPerOutput)
+	describe(c.VideoBitratePerOutput)
+	describe(c.DynamicBitratePerOutput)
+	describe(c.CurrentBitratePerOutput)
+	describe(c.AudioBitratePerOutput)
+
+	describe(c.InfoPerEncoder)
+	describe(c.WidthPerEncoder)
+	describe(c.HeightPerEncoder)
+	describe(c.SampleRatePerEncoder)
+	describe(c.ActivePerEncoder)
+	describe(c.PresetPerEncoder)
+	describe(c.CPUUsagePerEncoder)
+	describe(c.InstancesPerEncoder)
+	describe(c.GPUIndexPerEncoder)
+	describe(c.ReconfigurablePerEncoder)
+	describe(c.OutputDropsPerEncoder)
+	describe(c.FPSDivisorPerEncoder)
+
+	describe(c.MagnitudePerSourceChannel)
+	describe(c.PeakPerSourceChannel)
+	describe(c.InputPeakPerSourceChannel)
+	describe(c.ClippingPerSourceChannel)
+	describe(c.SessionPeakPerSourceChannel)
+	describe(c.PeakHoldPerSourceChannel)
+	describe(c.BalancePerSource)
+	describe(c.LatencyPerSource)
+	describe(c.PushToTalkPerSource)
+	describe(c.PushToMutePerSource)
+	describe(c.VolumeChangesPerSource)
+	describe(c.VolMeterUpdatesPerSource)
+	describe(c.AudioMixersPerSource)
+	describe(c.AudioTrackPerSource)
+	describe(c.GlobalChannelPerSource)
+	describe(c.AudioFilterParamPerSource)
+	describe(c.WidthPerSource)
+	describe(c.HeightPerSource)
+	describe(c.FrozenPerSource)
+	describe(c.SettingsHashPerSource)
+	describe(c.CaptureTargetPerSource)
+
+	describe(c.MissingSourcesPerScene)
+	describe(c.InfoPerScene)
+	describe(c.ActivePerScene)
+	describe(c.PreviewPerScene)
+	describe(c.ItemCountPerScene)
+	describe(c.IsGroupPerSource)
+	describe(c.MembersPerGroup)
+	describe(c.SceneRefsPerSource)
+	describe(c.EncoderInfoPerProfile)
+
+	describe(c.WebSocketEnabled)
+
+	describe(c.SourcesTruncated)
+	describe(c.BuildInfo)
+	describe(c.AudioBufferBytes)
+}
+
+func boolMetric(b bool) float64 {
+	if b {
 		return 1
 	}
 	return 0
 }
 
 func (c *MetricCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collect(ch, c.snapshot)
+}
+
+// collect emits the metrics in the snapshot taken by snapshot.
+func (c *MetricCollector) collect(ch chan<- prometheus.Metric, snapshot func(*Config) *collectorSnapshot) {
+	defer recoverCollectPanic(nil)
+	// The same config is used for the snapshot and emit, even if it's reloaded in between. It isn't
+	// held locked, since volmeter callbacks read it while holding their source's lock, which snapshot takes.
+	cfg := currentConfig()
+	// This doesn't come from OBS, so it's exported even while OBS is shutting down.
+	metricSink{ch, cfg}.send(c.BuildInfo, prometheus.GaugeValue, 1, exporterVersion, runtime.Version(), builtAgainstAPIVersion)
+	c.emit(ch, snapshot(cfg), cfg)
+}
+
+// snapshot reads everything we export from OBS.
+func (c *MetricCollector) snapshot(cfg *Config) *collectorSnapshot {
+	snap, dir := c.snapshotOBS(cfg)
+	if dir != "" {
+		snap.ProfileEncoders = readProfileEncoders(dir)
+	}
+	return snap
+}
+
+// snapshotOBS reads everything we export from OBS while holding obsLock. The profiles' files
+// are read afterwards from the directory it returns, if there is one, so they don't hold up OBS.
+func (c *MetricCollector) snapshotOBS(cfg *Config) (snap *collectorSnapshot, profileDir string) {
 	obsLock.Lock()
 	defer obsLock.Unlock()
 
-	ch <- prometheus.MustNewConstMetric(c.ActiveFPS, prometheus.GaugeValue, float64(C.obs_get_active_fps()))
-	ch <- prometheus.MustNewConstMetric(c.AverageFrameTimeNS, prometheus.GaugeValue, float64(C.obs_get_average_frame_time_ns()))
-	ch <- prometheus.MustNewConstMetric(c.TotalFrames, prometheus.CounterValue, float64(C.obs_get_total_frames()))
-	ch <- prometheus.MustNewConstMetric(c.LaggedFrames, prometheus.CounterValue, float64(C.obs_get_lagged_frames()))
+	if shuttingDown.Load() {
+		return &collectorSnapshot{}, ""
+	}
+	snap = &collectorSnapshot{
+		Up:       true,
+		Global:   c.snapshotGlobal(),
+		Outputs:  c.snapshotOutputs(),
+		Encoders: c.snapshotEncoders(cfg),
+	}
+	snap.Scenes, snap.Groups, snap.SceneReferences = c.snapshotScenes(snap.Global.ProgramScene.Name, snap.Global.PreviewScene.Name, cfg)
+	if cfg.ProfileEncoders && frontendAvailable {
+		profileDir, _ = profilesDir()
+	}
+	snap.Sources, snap.SourcesTruncated = c.snapshotSources(cfg)
+	snap.AudioBufferBytes = float64(c.audioBufferBytes())
+	snap.Global.ActiveFilters = c.snapshotActiveFilters()
+	return snap, profileDir
+}
 
+func (c *MetricCollector) snapshotGlobal() globalSnapshot {
 	vid := C.obs_get_video()
-	ch <- prometheus.MustNewConstMetric(c.VideoTotalFrames, prometheus.CounterValue, float64(C.video_output_get_total_frames(vid)))
-	ch <- prometheus.MustNewConstMetric(c.VideoSkippedFrames, prometheus.CounterValue, float64(C.video_output_get_skipped_frames(vid)))
+	g := globalSnapshot{
+		ActiveFPS:          float64(C.obs_get_active_fps()),
+		TargetFPS:          targetFPS(),
+		SDRWhiteNits:       float64(C.obs_get_video_sdr_white_level()),
+		AverageFrameTimeNS: float64(C.obs_get_average_frame_time_ns()),
+		TotalFrames:        float64(C.obs_get_total_frames()),
+		LaggedFrames:       float64(C.obs_get_lagged_frames()),
+		VideoTotalFrames:   float64(C.video_output_get_total_frames(vid)),
+		VideoSkippedFrames: float64(C.video_output_get_skipped_frames(vid)),
+		MemoryAllocations:  float64(C.bnum_allocs()),
+	}
+	g.Canvas, g.HasCanvas = snapshotCanvas()
+
+	g.PortableMode = portableMode
+	if frontendAvailable {
+		g.HasFrontend = true
+		g.SafeMode = safeMode
+		g.OutputMode = outputMode(profileConfigString("Output", "Mode"))
+		g.ProgramScene, g.PreviewScene, g.StudioMode = currentScenes()
+		g.StreamingActive, g.RecordingActive, g.RecordingPaused = frontendOutputState()
+		if length, ok := replayBufferLength(g.OutputMode, profileConfigString); ok {
+			g.ReplayBufferLength = float64(length)
+			g.HasReplayBuffer = true
+		}
+	}
+	g.MonitoringDeviceName, g.MonitoringDeviceID = audioMonitoringDevice()
+	if sampleRate, speakers, ok := audioInfo(); ok {
+		g.AudioSampleRate, g.AudioSpeakers, g.HasAudioInfo = float64(sampleRate), float64(speakers), true
+	}
+	g.WebSocketLoaded, g.WebSocketEnabled = websocketState()
+	return g
+}
+
+// sourceLatencyNS estimates the delay OBS adds to an audio source from its sync offset and
+// how long its audio is buffered for, both in nanoseconds. A negative sync offset plays the
+// source early, so the estimate can be negative.
+func sourceLatencyNS(syncOffset, buffering int64) float64 {
+	return float64(syncOffset + buffering)
+}
+
+// sourceLimit tracks the sources seen while enumerating them, up to a maximum.
+type sourceLimit struct {
+	max       int
+	seen      map[string]bool
+	truncated bool
+}
+
+func newSourceLimit(max int) *sourceLimit {
+	return &sourceLimit{max: max, seen: map[string]bool{}}
+}
+
+// admit reports whether a source should be tracked, and whether enumeration should go on.
+// Sources past the limit aren't tracked, so any we were tracking are pruned afterwards.
+func (l *sourceLimit) admit(uuid string) (track, more bool) {
+	if l.seen[uuid] {
+		return false, true
+	}
+	if l.max > 0 && len(l.seen) >= l.max {
+		l.truncated = true
+		return false, false
+	}
+	l.seen[uuid] = true
+	return true, true
+}
+
+// refreshSourceNames picks up the names of sources that have been renamed since the last scrape.
+// Renaming a source doesn't change its UUID, which is what the volmeter callback looks it up by,
+// so all we need to do is update the name. c.mu must be held.
+func (c *MetricCollector) refreshSourceNames(snaps []sourceSnapshot) {
+	for _, snap := range snaps {
+		if src, ok := c.sources[snap.UUID]; ok && src.Name != snap.Name {
+			if peakHistogram != nil {
+				peakHistogram.DeleteLabelValues(src.ID, src.Name)
+			}
+			src.Name = snap.Name
+		}
+	}
+}
 
+// snapshotSources also returns true if there were more sources than the configured maximum.
+func (c *MetricCollector) snapshotSources(cfg *Config) ([]sourceSnapshot, bool) {
 	c.mu.Lock()
-	seenSources := map[string]bool{}
+	defer c.mu.Unlock()
+
+	var snaps []sourceSnapshot
+	limit := newSourceLimit(cfg.MaxSources)
+	globalChannels := globalAudioChannels()
 	c.enumSourcesCB = func(v unsafe.Pointer, o *C.obs_source_t) C.bool {
-		idC := C.obs_source_get_id(o)
-		id := C.GoString(idC)
-		name := C.GoString(C.obs_source_get_name(o))
+		id := C.GoString(C.obs_source_get_id(o))
+		uuid := C.GoString(C.obs_source_get_uuid(o))
+		name := sourceLabelName(o, cfg.SourceNameTemplate)
 
-		if seenSources[id] {
-			return C.bool(true)
+		if track, more := limit.admit(uuid); !track {
+			return C.bool(more)
+		}
+
+		snap := sourceSnapshot{
+			ID:      id,
+			UUID:    uuid,
+			Name:    name,
+			IsAudio: C.obs_source_get_output_flags(o)&C.OBS_SOURCE_AUDIO != 0,
+		}
+		snap.GlobalChannel, snap.HasGlobalChannel = globalChannels[uuid]
+		snap.IsVideo = C.obs_source_get_output_flags(o)&C.OBS_SOURCE_VIDEO != 0
+		if snap.IsVideo {
+			snap.Width = uint32(C.obs_source_get_width(o))
+			snap.Height = uint32(C.obs_source_get_height(o))
+			snap.Frozen = sourceFrozen(bool(C.obs_source_showing(o)), snap.Width, snap.Height)
+		}
+		if snap.IsAudio {
+			snap.Balance = float64(C.obs_source_get_balance_value(o))
+			// libobs doesn't expose how much a source is buffered by, so the sync offset
+			// is the only part of the delay we can see.
+			snap.LatencyNS = sourceLatencyNS(int64(C.obs_source_get_sync_offset(o)), 0)
+			snap.Mixers = uint32(C.obs_source_get_audio_mixers(o))
+			snap.PushToTalk = bool(C.obs_source_push_to_talk_enabled(o))
+			snap.PushToMute = bool(C.obs_source_push_to_mute_enabled(o))
+			if cfg.AudioFilters {
+				snap.AudioFilters = c.snapshotAudioFilters(o)
+			}
+		}
+		settingsJSON := sourceSettingsJSON(o)
+		snap.SettingsHash = settingsHash(settingsJSON)
+		if cfg.CaptureTargets {
+			snap.CaptureTarget, _ = captureTarget(id, settingsJSON)
 		}
-		seenSources[id] = true
 
-		src, ok := c.sources[id]
+		src, ok := c.sources[uuid]
 		if !ok {
+			snaps = append(snaps, snap)
+
 			src = &Source{
 				ID:   id,
+				UUID: uuid,
 				Name: name,
-				CID:  C.CString(id),
+				CID:  C.CString(uuid),
 			}
-			negInf := math.Inf(-1)
 			vm := C.obs_volmeter_create(C.OBS_FADER_CUBIC)
 			if vm == nil {
+				countError(errorVolmeterCreate)
 				slog.Warn("failed to create volmeter", "source_id", id, "source_name", name)
 				return C.bool(true)
 			}
 			src.VolMeter = vm
 			if ok := bool(C.obs_volmeter_attach_source(vm, o)); !ok {
+				countError(errorVolmeterAttach)
 				slog.Warn("failed to attach source to volmeter", "source_id", id, "source_name", name)
 				C.obs_volmeter_destroy(vm)
 				return C.bool(true)
 			}
 			C.obs_volmeter_add_callback(vm, C.obs_volmeter_updated_t(C.mc_volmeter_updated), unsafe.Pointer(src.CID))
 
-			//src.Channels = int(C.obs_volmeter_get_nr_channels(vm))
-			src.Channels = 2
-			src.Magnitude = make([][circBufSamples]float64, src.Channels)
-			src.Peak = make([][circBufSamples]float64, src.Channels)
-			src.InputPeak = make([][circBufSamples]float64, src.Channels)
-			for ch := 0; ch < src.Channels; ch++ {
-				var magnitude, peak, inputPeak [circBufSamples]float64
-				for n := 0; n < circBufSamples; n++ {
-					magnitude[n] = negInf
-					peak[n] = negInf
-					inputPeak[n] = negInf
-				}
-				src.Magnitude[ch] = magnitude
-				src.Peak[ch] = peak
-				src.InputPeak[ch] = inputPeak
-			}
+			src.resizeChannels(volmeterChannels(vm))
 
-			c.sources[id] = src
+			c.trackSource(src)
+			src.connectSignals(o)
 		} else {
-			ninf := math.Inf(-1)
-			for chn := 0; chn < src.Channels; chn++ {
-				magnitude := ninf
-				peak := ninf
-				inputPeak := ninf
-				for n := 0; n < circBufSamples; n++ {
-					magnitude = math.Max(magnitude, src.Magnitude[chn][n])
-					peak = math.Max(peak, src.Peak[chn][n])
-					inputPeak = math.Max(inputPeak, src.InputPeak[chn][n])
-				}
-				chnstr := fmt.Sprintf("%d", chn)
-				ch <- prometheus.MustNewConstMetric(c.MagnitudePerSourceChannel, prometheus.GaugeValue, magnitude, src.ID, src.Name, chnstr)
-				ch <- prometheus.MustNewConstMetric(c.PeakPerSourceChannel, prometheus.GaugeValue, peak, src.ID, src.Name, chnstr)
-				ch <- prometheus.MustNewConstMetric(c.InputPeakPerSourceChannel, prometheus.GaugeValue, inputPeak, src.ID, src.Name, chnstr)
+			// Sources can change their speaker layout, or only get one once their audio starts.
+			if n := volmeterChannels(src.VolMeter); n != src.Channels {
+				src.resizeChannels(n)
 			}
+			snap.Meter = src.snapshotMeter(cfg)
+			snaps = append(snaps, snap)
 		}
 		return C.bool(true)
 	}
 	C.obs_enum_sources(C.mc_enum_sources_proc(C.mc_enum_sources_cb), nil)
-	for id, s := range c.sources {
-		if seenSources[id] {
+	uniqueSourceNames(snaps)
+	c.refreshSourceNames(snaps)
+	c.pruneSources(limit.seen)
+	c.sourcesEnumerated.Store(true)
+	return snaps, limit.truncated
+}
+
+// trackSource starts tracking a source once its volmeter is set up. c.mu must be held.
+func (c *MetricCollector) trackSource(src *Source) {
+	c.sources[src.UUID] = src
+	sourceChurn.WithLabelValues("added").Inc()
+}
+
+// pruneSources stops tracking the sources that weren't seen this scrape. c.mu must be held.
+func (c *MetricCollector) pruneSources(seen map[string]bool) {
+	for uuid, s := range c.sources {
+		if seen[uuid] {
 			continue
 		}
-		// Delete s.
-		delete(c.sources, id)
-		C.free(unsafe.Pointer(s.CID))
-		if s.VolMeter != nil {
-			C.obs_volmeter_destroy(s.VolMeter)
-		}
+		c.removeSource(s)
+		sourceChurn.WithLabelValues("removed").Inc()
+	}
+}
+
+// removeSource stops tracking a source, tearing down its volmeter and signal handlers.
+// It must be called with c.mu held.
+func (c *MetricCollector) removeSource(s *Source) {
+	delete(c.sources, s.UUID)
+	if peakHistogram != nil {
+		peakHistogram.DeleteLabelValues(s.ID, s.Name)
+	}
+	s.disconnectSignals()
+	if s.VolMeter != nil {
+		C.obs_volmeter_destroy(s.VolMeter)
+	}
+	C.free(unsafe.Pointer(s.CID))
+}
+
+// close releases everything the collector holds in OBS: every source's volmeter and signal
+// handlers, and every output's signal handlers. It's called when the module is unloaded.
+func (c *MetricCollector) close() {
+	obsLock.Lock()
+	defer obsLock.Unlock()
+
+	c.mu.Lock()
+	for _, s := range c.sources {
+		c.removeSource(s)
 	}
 	c.mu.Unlock()
 
+	for name, state := range c.outputs {
+		state.disconnectEvents(name)
+		delete(c.outputs, name)
+	}
+	c.encoderDrops = nil
+}
+
+// levelBufferBytes is the size of the magnitude, peak and input peak buffers for one channel.
+const levelBufferBytes = 3 * int(unsafe.Sizeof([circBufSamples]float64{}))
+
+// audioBufferBytes returns the memory allocated for the tracked sources' level buffers.
+func (c *MetricCollector) audioBufferBytes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var channels int
+	for _, s := range c.sources {
+		channels += len(s.Magnitude)
+	}
+	return channels * levelBufferBytes
+}
+
+// volmeterChannels returns how many channels a volmeter reports levels for.
+func volmeterChannels(vm *C.obs_volmeter_t) int {
+	return clampChannels(int(C.obs_volmeter_get_nr_channels(vm)))
+}
+
+// maxAudioChannels is the most channels a volmeter reports levels for.
+const maxAudioChannels = C.MAX_AUDIO_CHANNELS
+
+// clampChannels limits a channel count to what the volmeter callback can pass us.
+func clampChannels(n int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > maxAudioChannels {
+		return maxAudioChannels
+	}
+	return n
+}
+
+// resizeChannels reallocates the per-channel state for n channels. The levels are reset,
+// since they can't be matched up with the old channels.
+func (s *Source) resizeChannels(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	negInf := math.Inf(-1)
+	s.Channels = n
+	s.Magnitude = make([][circBufSamples]float64, n)
+	s.Peak = make([][circBufSamples]float64, n)
+	s.InputPeak = make([][circBufSamples]float64, n)
+	s.Clipping = make([]uint64, n)
+	s.SessionPeak = make([]float64, n)
+	s.PeakHold = make([]peakHold, n)
+	for ch := 0; ch < n; ch++ {
+		for i := 0; i < circBufSamples; i++ {
+			s.Magnitude[ch][i] = negInf
+			s.Peak[ch][i] = negInf
+			s.InputPeak[ch][i] = negInf
+		}
+		s.SessionPeak[ch] = negInf
+		s.PeakHold[ch] = peakHold{Level: negInf}
+	}
+}
+
+func (s *Source) snapshotMeter(cfg *Config) *sourceMeterSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meter := &sourceMeterSnapshot{
+		VolumeChanges:   s.VolumeChanges,
+		VolMeterUpdates: s.VolMeterUpdates,
+		Channels:        make([]channelSnapshot, s.Channels),
+	}
+	now := time.Now()
+	for chn := 0; chn < s.Channels; chn++ {
+		cs := channelSnapshot{
+			Clipping:    s.Clipping[chn],
+			SessionPeak: s.SessionPeak[chn],
+			PeakHold:    s.PeakHold[chn].value(now, cfg.PeakHold, cfg.PeakDecay),
+		}
+		cs.Magnitude, cs.MagnitudeTime = windowMax(&s.Magnitude[chn], &s.SampleTimes)
+		cs.Peak, cs.PeakTime = windowMax(&s.Peak[chn], &s.SampleTimes)
+		cs.InputPeak, cs.InputPeakTime = windowMax(&s.InputPeak[chn], &s.SampleTimes)
+		meter.Channels[chn] = cs
+	}
+	return meter
+}
+
+// windowMax returns the maximum of the circular buffer samples, and when it was sampled.
+// The time is zero if there have been no samples yet.
+func windowMax(samples *[circBufSamples]float64, times *[circBufSamples]time.Time) (float64, time.Time) {
+	max := math.Inf(-1)
+	var at time.Time
+	for n := 0; n < circBufSamples; n++ {
+		if samples[n] > max || (samples[n] == max && times[n].After(at)) {
+			max = samples[n]
+			at = times[n]
+		}
+	}
+	return max, at
+}
+
+// combineChannels merges the channels of a source into one, taking the loudest of each level and the total clipping.
+func combineChannels(channels []channelSnapshot) channelSnapshot {
+	ninf := math.Inf(-1)
+	combined := channelSnapshot{Magnitude: ninf, Peak: ninf, InputPeak: ninf, SessionPeak: ninf, PeakHold: ninf}
+	for _, cs := range channels {
+		if cs.Magnitude > combined.Magnitude {
+			combined.Magnitude, combined.MagnitudeTime = cs.Magnitude, cs.MagnitudeTime
+		}
+		if cs.Peak > combined.Peak {
+			combined.Peak, combined.PeakTime = cs.Peak, cs.PeakTime
+		}
+		if cs.InputPeak > combined.InputPeak {
+			combined.InputPeak, combined.InputPeakTime = cs.InputPeak, cs.InputPeakTime
+		}
+		combined.Clipping += cs.Clipping
+		combined.SessionPeak = math.Max(combined.SessionPeak, cs.SessionPeak)
+		combined.PeakHold = math.Max(combined.PeakHold, cs.PeakHold)
+	}
+	return combined
+}
+
+func (c *MetricCollector) snapshotOutputs() []outputSnapshot {
+	var snaps []outputSnapshot
+	seenOutputs := map[string]bool{}
+	drops := encoderDrops{}
 	c.enumOutputsCB = func(v unsafe.Pointer, o *C.obs_output_t) C.bool {
 		idC := C.obs_output_get_id(o)
 		id := C.GoString(idC)
 		name := C.GoString(C.obs_output_get_name(o))
-		displayName := C.GoString(C.obs_output_get_display_name(idC))
-
-		ch <- prometheus.MustNewConstMetric(c.InfoPerOutput, prometheus.GaugeValue, 1, id, name, displayName)
-		ch <- prometheus.MustNewConstMetric(c.OutputActivePerOutput, prometheus.GaugeValue, obsBoolMetric(C.obs_output_active(o)), id, name)
-		ch <- prometheus.MustNewConstMetric(c.TotalBytesPerOutput, prometheus.CounterValue, float64(C.obs_output_get_total_bytes(o)), id, name)
-		ch <- prometheus.MustNewConstMetric(c.DroppedFramesPerOutput, prometheus.CounterValue, float64(C.obs_output_get_frames_dropped(o)), id, name)
-		ch <- prometheus.MustNewConstMetric(c.TotalFramesPerOutput, prometheus.GaugeValue, float64(C.obs_output_get_total_frames(o)), id, name)
-		ch <- prometheus.MustNewConstMetric(c.WidthPerOutput, prometheus.GaugeValue, float64(C.obs_output_get_width(o)), id, name)
-		ch <- prometheus.MustNewConstMetric(c.HeightPerOutput, prometheus.GaugeValue, float64(C.obs_output_get_height(o)), id, name)
-		ch <- prometheus.MustNewConstMetric(c.CongestionPerOutput, prometheus.GaugeValue, float64(C.obs_output_get_congestion(o)), id, name)
-		ch <- prometheus.MustNewConstMetric(c.ConnectTimePerOutput, prometheus.GaugeValue, float64(C.obs_output_get_connect_time_ms(o))/1000.0, id, name)
-		ch <- prometheus.MustNewConstMetric(c.ReconnectingPerOutput, prometheus.GaugeValue, obsBoolMetric(C.obs_output_reconnecting(o)), id, name)
 
+		snap := outputSnapshot{
+			ID:            id,
+			Name:          name,
+			DisplayName:   C.GoString(C.obs_output_get_display_name(idC)),
+			Kind:          outputKind(id, C.obs_output_get_flags(o)&C.OBS_OUTPUT_SERVICE != 0),
+			Active:        bool(C.obs_output_active(o)),
+			Reconnecting:  bool(C.obs_output_reconnecting(o)),
+			TotalBytes:    float64(C.obs_output_get_total_bytes(o)),
+			DroppedFrames: float64(C.obs_output_get_frames_dropped(o)),
+			TotalFrames:   float64(C.obs_output_get_total_frames(o)),
+			Width:         float64(C.obs_output_get_width(o)),
+			Height:        float64(C.obs_output_get_height(o)),
+			Congestion:    float64(C.obs_output_get_congestion(o)),
+			ConnectTime:   float64(C.obs_output_get_connect_time_ms(o)) / 1000.0,
+		}
+
+		seenOutputs[name] = true
+		state, ok := c.outputs[name]
+		if !ok {
+			state = &outputState{ID: id}
+			state.connectEvents(o, name)
+			c.outputs[name] = state
+		}
+		state.observe(name, snap.Active, int(snap.DroppedFrames), snap.ConnectTime)
+		snap.SessionDropped = float64(state.SessionDropped)
+		snap.NetworkDropped = float64(state.updateNetworkDrops(int(snap.DroppedFrames), snap.Congestion))
+		snap.ServerHost, _ = outputServerHost(o)
+		drops.attribute(unsafe.Pointer(C.obs_output_get_video_encoder(o)), snap.DroppedFrames)
+		if video := C.obs_output_video(o); video != nil {
+			snap.HasVideo = true
+			snap.VideoSkippedFrames = float64(C.video_output_get_skipped_frames(video))
+		}
+		snap.ReconnectDelayRemaining, snap.HasReconnectDelay = outputReconnectDelay(name, snap.Reconnecting, time.Now())
+		snap.ScaleType, snap.Rescaling = outputRescale(uint32(snap.Width), uint32(snap.Height))
+		// Raw outputs, like the virtual camera, don't use encoders.
+		if flags := C.obs_output_get_flags(o); flags&C.OBS_OUTPUT_ENCODED != 0 {
+			snap.EncodesVideo = flags&C.OBS_OUTPUT_VIDEO != 0
+			snap.EncodesAudio = flags&C.OBS_OUTPUT_AUDIO != 0
+			snap.HasVideoEncoder, snap.HasAudioEncoder = outputHasEncoders(o)
+		}
+		videoWeight, audioWeight := outputEncoderBitrates(o)
+		snap.VideoKbps, snap.AudioKbps, snap.HasBitrates = state.updateBitrate(uint64(snap.TotalBytes), time.Now(), videoWeight, audioWeight)
+		snap.DynamicBitrate, snap.CurrentKbps, snap.HasCurrentBitrate = outputDynamicBitrate(o)
+
+		snaps = append(snaps, snap)
 		return C.bool(true)
 	}
 	C.obs_enum_outputs(C.mc_enum_outputs_proc(C.mc_enum_outputs_cb), nil)
+	for name, state := range c.outputs {
+		if !seenOutputs[name] {
+			delete(c.outputs, name)
+			outputConnectTimes.DeleteLabelValues(state.ID, name)
+			state.disconnectEvents(name)
+		}
+	}
+	c.encoderDrops = drops
+	return snaps
+}
 
+func (c *MetricCollector) snapshotEncoders(cfg *Config) []encoderSnapshot {
+	var snaps []encoderSnapshot
+	baseFPS := targetFPS()
+	var softwareEncoders []int
 	c.enumEncodersCB = func(v unsafe.Pointer, o *C.obs_encoder_t) C.bool {
 		idC := C.obs_encoder_get_id(o)
-		id := C.GoString(idC)
-		name := C.GoString(C.obs_encoder_get_name(o))
-		displayName := C.GoString(C.obs_encoder_get_display_name(idC))
-		isAudioEncoder := C.obs_encoder_get_type(o) == C.OBS_ENCODER_AUDIO
-
-		ch <- prometheus.MustNewConstMetric(c.InfoPerEncoder, prometheus.GaugeValue, 1, id, name, displayName, C.GoString(C.obs_encoder_get_codec(o)))
-		ch <- prometheus.MustNewConstMetric(c.ActivePerEncoder, prometheus.GaugeValue, obsBoolMetric(C.obs_encoder_active(o)), id, name)
-
-		if isAudioEncoder {
-			ch <- prometheus.MustNewConstMetric(c.WidthPerEncoder, prometheus.GaugeValue, 0, id, name)
-			ch <- prometheus.MustNewConstMetric(c.HeightPerEncoder, prometheus.GaugeValue, 0, id, name)
-			ch <- prometheus.MustNewConstMetric(c.SampleRatePerEncoder, prometheus.GaugeValue, float64(C.obs_encoder_get_sample_rate(o)), id, name)
+		snap := encoderSnapshot{
+			ID:             C.GoString(idC),
+			Name:           C.GoString(C.obs_encoder_get_name(o)),
+			DisplayName:    C.GoString(C.obs_encoder_get_display_name(idC)),
+			Codec:          C.GoString(C.obs_encoder_get_codec(o)),
+			Active:         bool(C.obs_encoder_active(o)),
+			IsAudio:        C.obs_encoder_get_type(o) == C.OBS_ENCODER_AUDIO,
+			Settings:       getEncoderSettings(o),
+			Reconfigurable: encoderReconfigurable(uint32(C.obs_encoder_get_caps(o))),
+		}
+		if snap.IsAudio {
+			snap.SampleRate = float64(C.obs_encoder_get_sample_rate(o))
+			snap.Track = int(C.obs_encoder_get_mixer_index(o)) + 1
 		} else {
-			ch <- prometheus.MustNewConstMetric(c.WidthPerEncoder, prometheus.GaugeValue, float64(C.obs_encoder_get_width(o)), id, name)
-			ch <- prometheus.MustNewConstMetric(c.HeightPerEncoder, prometheus.GaugeValue, float64(C.obs_encoder_get_height(o)), id, name)
-			ch <- prometheus.MustNewConstMetric(c.SampleRatePerEncoder, prometheus.GaugeValue, 0, id, name)
+			snap.Width = float64(C.obs_encoder_get_width(o))
+			snap.Height = float64(C.obs_encoder_get_height(o))
+			snap.FPSDivisor, snap.HasFPSDivisor = fpsDivisor(baseFPS, encoderFPS(o))
 		}
 
+		snap.OutputDroppedFrames, snap.HasOutputDrops = c.encoderDrops.dropped(unsafe.Pointer(o))
+
+		if snap.Active && softwareVideoEncoders[snap.ID] {
+			softwareEncoders = append(softwareEncoders, len(snaps))
+		}
+		snaps = append(snaps, snap)
 		return C.bool(true)
 	}
 	C.obs_enum_encoders(C.mc_enum_encoders_proc(C.mc_enum_encoders_cb), nil)
+	uniqueEncoderNames(snaps)
+	if cfg.EncoderCPU {
+		percent, ok := c.encoderCPU.sample(time.Now())
+		// We can't tell encoders sharing the thread apart.
+		if ok && len(softwareEncoders) == 1 {
+			snaps[softwareEncoders[0]].CPUPercent = percent
+			snaps[softwareEncoders[0]].HasCPUPercent = true
+		}
+	}
+	return snaps
+}
+
+// emitOutputDrops sends the dropped frame metrics for every output, so the per-output
+// series and their total always come from the same snapshot.
+func (c *MetricCollector) emitOutputDrops(out metricSink, outputs []outputSnapshot) {
+	var total float64
+	for _, o := range outputs {
+		total += o.DroppedFrames
+		out.send(c.DroppedFramesPerOutput, prometheus.CounterValue, o.DroppedFrames, o.ID, o.Name)
+		if ratio, ok := droppedFramesRatio(o.DroppedFrames, o.TotalFrames); ok {
+			out.send(c.DroppedFramesRatioPerOutput, prometheus.GaugeValue, ratio, o.ID, o.Name)
+		}
+	}
+	out.send(c.TotalDroppedFrames, prometheus.GaugeValue, total)
+}
+
+// emit sends metrics for a snapshot. It must not call into OBS.
+func (c *MetricCollector) emit(ch chan<- prometheus.Metric, snap *collectorSnapshot, cfg *Config) {
+	out := metricSink{ch, cfg}
+	if !snap.Up {
+		out.send(c.Up, prometheus.GaugeValue, 0)
+		return
+	}
+	out.send(c.Up, prometheus.GaugeValue, 1)
+
+	g := snap.Global
+	out.send(c.ActiveFPS, prometheus.GaugeValue, g.ActiveFPS)
+	if ratio, ok := fpsRatio(g.ActiveFPS, g.TargetFPS); ok {
+		out.send(c.FPSRatio, prometheus.GaugeValue, ratio)
+	}
+	if g.HasCanvas {
+		cv := g.Canvas
+		out.send(c.BaseWidth, prometheus.GaugeValue, float64(cv.BaseWidth))
+		out.send(c.BaseHeight, prometheus.GaugeValue, float64(cv.BaseHeight))
+		out.send(c.ColorspaceInfo, prometheus.GaugeValue, 1, cv.Colorspace, cv.Range, strconv.FormatFloat(g.SDRWhiteNits, 'f', -1, 64))
+	}
+	out.send(c.AverageFrameTimeNS, prometheus.GaugeValue, g.AverageFrameTimeNS)
+	out.send(c.TotalFrames, prometheus.CounterValue, g.TotalFrames)
+	out.send(c.LaggedFrames, prometheus.CounterValue, g.LaggedFrames)
+	out.send(c.VideoTotalFrames, prometheus.CounterValue, g.VideoTotalFrames)
+	out.send(c.VideoSkippedFrames, prometheus.CounterValue, g.VideoSkippedFrames)
+	out.send(c.RenderLagPercent, prometheus.GaugeValue, lagPercent(g.LaggedFrames, g.TotalFrames))
+	out.send(c.EncodeLagPercent, prometheus.GaugeValue, lagPercent(g.VideoSkippedFrames, g.VideoTotalFrames))
+	out.send(c.StreamHealthScore, prometheus.GaugeValue, snapshotHealthScore(snap, cfg.HealthWeights))
+	out.send(c.MemoryAllocations, prometheus.GaugeValue, g.MemoryAllocations)
+	out.send(c.ActiveFilters, prometheus.GaugeValue, float64(g.ActiveFilters))
+	out.send(c.PortableMode, prometheus.GaugeValue, boolMetric(g.PortableMode))
+	if g.HasFrontend {
+		out.send(c.SafeMode, prometheus.GaugeValue, boolMetric(g.SafeMode))
+		out.send(c.OutputModeInfo, prometheus.GaugeValue, 1, g.OutputMode)
+		out.send(c.StreamingActive, prometheus.GaugeValue, boolMetric(g.StreamingActive))
+		out.send(c.RecordingActive, prometheus.GaugeValue, boolMetric(g.RecordingActive))
+		out.send(c.RecordingPaused, prometheus.GaugeValue, boolMetric(g.RecordingPaused))
+		if g.ProgramScene.Name != "" {
+			out.send(c.ProgramScene, prometheus.GaugeValue, 1, g.ProgramScene.Name)
+		}
+		if g.PreviewScene.Name != "" {
+			out.send(c.PreviewScene, prometheus.GaugeValue, 1, g.PreviewScene.Name)
+		}
+		// Outside studio mode there's no preview, and the program is just the main canvas.
+		if g.StudioMode {
+			if g.ProgramScene.Name != "" {
+				out.send(c.ProgramWidth, prometheus.GaugeValue, float64(g.ProgramScene.Width))
+				out.send(c.ProgramHeight, prometheus.GaugeValue, float64(g.ProgramScene.Height))
+			}
+			if g.PreviewScene.Name != "" {
+				out.send(c.PreviewWidth, prometheus.GaugeValue, float64(g.PreviewScene.Width))
+				out.send(c.PreviewHeight, prometheus.GaugeValue, float64(g.PreviewScene.Height))
+			}
+		}
+	}
+	if g.HasReplayBuffer {
+		out.send(c.ReplayBufferLength, prometheus.GaugeValue, g.ReplayBufferLength)
+	}
+	out.send(c.AudioMonitoringDeviceInfo, prometheus.GaugeValue, 1, g.MonitoringDeviceName, g.MonitoringDeviceID)
+	if g.HasAudioInfo {
+		out.send(c.AudioSampleRate, prometheus.GaugeValue, g.AudioSampleRate)
+		out.send(c.AudioSpeakers, prometheus.GaugeValue, g.AudioSpeakers)
+	}
+	if g.WebSocketLoaded {
+		out.send(c.WebSocketEnabled, prometheus.GaugeValue, boolMetric(g.WebSocketEnabled))
+	}
+
+	out.send(c.SourcesTruncated, prometheus.GaugeValue, boolMetric(snap.SourcesTruncated))
+	out.send(c.AudioBufferBytes, prometheus.GaugeValue, snap.AudioBufferBytes)
+	for _, s := range snap.Sources {
+		if s.IsAudio {
+			out.send(c.BalancePerSource, prometheus.GaugeValue, s.Balance, s.ID, s.Name)
+			out.send(c.LatencyPerSource, prometheus.GaugeValue, s.LatencyNS, s.ID, s.Name)
+			out.send(c.PushToTalkPerSource, prometheus.GaugeValue, boolMetric(s.PushToTalk), s.ID, s.Name)
+			out.send(c.PushToMutePerSource, prometheus.GaugeValue, boolMetric(s.PushToMute), s.ID, s.Name)
+			out.send(c.AudioMixersPerSource, prometheus.GaugeValue, float64(s.Mixers), s.ID, s.Name)
+			if cfg.AudioTracks {
+				for n, enabled := range audioMixerTracks(s.Mixers) {
+					out.send(c.AudioTrackPerSource, prometheus.GaugeValue, boolMetric(enabled), s.ID, s.Name, fmt.Sprintf("%d", n+1))
+				}
+			}
+		}
+		if s.IsVideo {
+			out.send(c.WidthPerSource, prometheus.GaugeValue, float64(s.Width), s.ID, s.Name)
+			out.send(c.HeightPerSource, prometheus.GaugeValue, float64(s.Height), s.ID, s.Name)
+			out.send(c.FrozenPerSource, prometheus.GaugeValue, boolMetric(s.Frozen), s.ID, s.Name)
+		}
+		for _, f := range s.AudioFilters {
+			for param, v := range f.Params {
+				out.send(c.AudioFilterParamPerSource, prometheus.GaugeValue, v, s.ID, s.Name, f.ID, f.Name, param)
+			}
+		}
+		if s.HasGlobalChannel {
+			out.send(c.GlobalChannelPerSource, prometheus.GaugeValue, float64(s.GlobalChannel), s.ID, s.Name)
+		}
+		out.send(c.SettingsHashPerSource, prometheus.GaugeValue, float64(s.SettingsHash), s.ID, s.Name)
+		if s.CaptureTarget != "" {
+			out.send(c.CaptureTargetPerSource, prometheus.GaugeValue, 1, s.Name, s.CaptureTarget)
+		}
+		if s.Meter == nil {
+			continue
+		}
+		out.send(c.VolumeChangesPerSource, prometheus.CounterValue, float64(s.Meter.VolumeChanges), s.ID, s.Name)
+		out.send(c.VolMeterUpdatesPerSource, prometheus.CounterValue, float64(s.Meter.VolMeterUpdates), s.ID, s.Name)
+		channels := s.Meter.Channels
+		if cfg.CombineChannels {
+			channels = []channelSnapshot{combineChannels(channels)}
+		}
+		for chn, cs := range channels {
+			labels := []string{s.ID, s.Name}
+			if !cfg.CombineChannels {
+				labels = append(labels, fmt.Sprintf("%d", chn))
+			}
+			out.sendAt(cs.MagnitudeTime, c.MagnitudePerSourceChannel, prometheus.GaugeValue, cs.Magnitude, labels...)
+			out.sendAt(cs.PeakTime, c.PeakPerSourceChannel, prometheus.GaugeValue, cs.Peak, labels...)
+			out.sendAt(cs.InputPeakTime, c.InputPeakPerSourceChannel, prometheus.GaugeValue, cs.InputPeak, labels...)
+			out.send(c.ClippingPerSourceChannel, prometheus.CounterValue, float64(cs.Clipping), labels...)
+			out.send(c.SessionPeakPerSourceChannel, prometheus.GaugeValue, cs.SessionPeak, labels...)
+			out.send(c.PeakHoldPerSourceChannel, prometheus.GaugeValue, cs.PeakHold, labels...)
+		}
+	}
+
+	c.emitOutputDrops(out, snap.Outputs)
+	for _, o := range snap.Outputs {
+		out.send(c.InfoPerOutput, prometheus.GaugeValue, 1, o.ID, o.Name, o.DisplayName)
+		out.send(c.KindPerOutput, prometheus.GaugeValue, 1, o.ID, o.Name, o.Kind)
+		if o.ServerHost != "" {
+			out.send(c.ServerHostPerOutput, prometheus.GaugeValue, 1, o.ID, o.Name, o.ServerHost)
+		}
+		out.send(c.OutputActivePerOutput, prometheus.GaugeValue, boolMetric(o.Active), o.ID, o.Name)
+		out.send(c.TotalBytesPerOutput, prometheus.CounterValue, o.TotalBytes, o.ID, o.Name)
+		out.send(c.TotalFramesPerOutput, prometheus.GaugeValue, o.TotalFrames, o.ID, o.Name)
+		out.send(c.WidthPerOutput, prometheus.GaugeValue, o.Width, o.ID, o.Name)
+		out.send(c.HeightPerOutput, prometheus.GaugeValue, o.Height, o.ID, o.Name)
+		if o.HasVideo {
+			out.send(c.VideoSkippedFramesPerOutput, prometheus.CounterValue, o.VideoSkippedFrames, o.ID, o.Name)
+		}
+		if o.Rescaling {
+			out.send(c.RescalePerOutput, prometheus.GaugeValue, 1, o.ID, o.Name, o.ScaleType)
+		}
+		out.send(c.CongestionPerOutput, prometheus.GaugeValue, o.Congestion, o.ID, o.Name)
+		out.send(c.ConnectTimePerOutput, prometheus.GaugeValue, o.ConnectTime, o.ID, o.Name)
+		out.send(c.ReconnectingPerOutput, prometheus.GaugeValue, boolMetric(o.Reconnecting), o.ID, o.Name)
+		if o.HasReconnectDelay {
+			out.send(c.ReconnectDelayPerOutput, prometheus.GaugeValue, o.ReconnectDelayRemaining, o.ID, o.Name)
+		}
+		out.send(c.SessionDroppedFramesPerOutput, prometheus.GaugeValue, o.SessionDropped, o.ID, o.Name)
+		out.send(c.NetworkDroppedFramesPerOutput, prometheus.CounterValue, o.NetworkDropped, o.ID, o.Name)
+		if o.EncodesVideo {
+			out.send(c.HasVideoEncoderPerOutput, prometheus.GaugeValue, boolMetric(o.HasVideoEncoder), o.ID, o.Name)
+		}
+		if o.EncodesAudio {
+			out.send(c.HasAudioEncoderPerOutput, prometheus.GaugeValue, boolMetric(o.HasAudioEncoder), o.ID, o.Name)
+		}
+		if o.HasBitrates {
+			out.send(c.VideoBitratePerOutput, prometheus.GaugeValue, o.VideoKbps, o.ID, o.Name)
+			out.send(c.AudioBitratePerOutput, prometheus.GaugeValue, o.AudioKbps, o.ID, o.Name)
+		}
+		out.send(c.DynamicBitratePerOutput, prometheus.GaugeValue, boolMetric(o.DynamicBitrate), o.ID, o.Name)
+		if o.HasCurrentBitrate {
+			out.send(c.CurrentBitratePerOutput, prometheus.GaugeValue, o.CurrentKbps, o.ID, o.Name)
+		}
+	}
+
+	encoderInstances := map[string]int{}
+	for _, e := range snap.Encoders {
+		encoderInstances[e.ID]++
+		out.send(c.InfoPerEncoder, prometheus.GaugeValue, 1, e.ID, e.Name, e.DisplayName, e.Codec)
+		out.send(c.ActivePerEncoder, prometheus.GaugeValue, boolMetric(e.Active), e.ID, e.Name)
+		if e.Settings.Preset != "" {
+			out.send(c.PresetPerEncoder, prometheus.GaugeValue, 1, e.ID, e.Name, e.Settings.Preset)
+		}
+		out.send(c.WidthPerEncoder, prometheus.GaugeValue, e.Width, e.ID, e.Name)
+		out.send(c.HeightPerEncoder, prometheus.GaugeValue, e.Height, e.ID, e.Name)
+		out.send(c.SampleRatePerEncoder, prometheus.GaugeValue, e.SampleRate, e.ID, e.Name)
+		if e.HasFPSDivisor {
+			out.send(c.FPSDivisorPerEncoder, prometheus.GaugeValue, float64(e.FPSDivisor), e.ID, e.Name)
+		}
+		out.send(c.GPUIndexPerEncoder, prometheus.GaugeValue, float64(e.Settings.GPU), e.ID, e.Name)
+		out.send(c.ReconfigurablePerEncoder, prometheus.GaugeValue, boolMetric(e.Reconfigurable), e.ID, e.Name)
+		if e.HasOutputDrops {
+			out.send(c.OutputDropsPerEncoder, prometheus.CounterValue, e.OutputDroppedFrames, e.ID, e.Name)
+		}
+		if e.HasCPUPercent {
+			out.send(c.CPUUsagePerEncoder, prometheus.GaugeValue, e.CPUPercent, e.ID, e.Name)
+		}
+	}
+	for id, n := range encoderInstances {
+		out.send(c.InstancesPerEncoder, prometheus.GaugeValue, float64(n), id)
+	}
+
+	for _, s := range snap.Scenes {
+		out.send(c.MissingSourcesPerScene, prometheus.GaugeValue, float64(s.MissingSources), s.Name)
+		out.send(c.InfoPerScene, prometheus.GaugeValue, 1, s.Name, s.UUID)
+		out.send(c.ItemCountPerScene, prometheus.GaugeValue, float64(s.Items), s.Name)
+		if s.HasFrontend {
+			out.send(c.ActivePerScene, prometheus.GaugeValue, boolMetric(s.Program), s.Name)
+			out.send(c.PreviewPerScene, prometheus.GaugeValue, boolMetric(s.Preview), s.Name)
+		}
+		if cfg.Groups {
+			out.send(c.IsGroupPerSource, prometheus.GaugeValue, 0, s.Name)
+		}
+	}
+	for _, g := range snap.Groups {
+		out.send(c.IsGroupPerSource, prometheus.GaugeValue, 1, g.Name)
+		out.send(c.MembersPerGroup, prometheus.GaugeValue, float64(g.Members), g.Name)
+	}
+	if cfg.SceneReferences {
+		// Sources that aren't in any scene aren't in SceneReferences, so are reported as 0.
+		for _, s := range snap.Sources {
+			out.send(c.SceneRefsPerSource, prometheus.GaugeValue, float64(snap.SceneReferences[s.UUID]), s.Name)
+		}
+	}
+
+	for _, p := range snap.ProfileEncoders {
+		out.send(c.EncoderInfoPerProfile, prometheus.GaugeValue, 1, p.Profile, p.EncoderID, strconv.Itoa(p.Bitrate))
+	}
+}
+
+// registeredCollectors is everything registerMetrics registered, so that unregisterMetrics can
+// undo it and the module can be loaded again.
+var registeredCollectors []prometheus.Collector
+
+func mustRegister(cs ...prometheus.Collector) {
+	prometheus.MustRegister(cs...)
+	registeredCollectors = append(registeredCollectors, cs...)
 }
 
 func registerMetrics() {
 	activeMetricCollector = NewMetricCollector()
-	prometheus.MustRegister(activeMetricCollector)
+	mustRegister(activeMetricCollector)
+	mustRegister(goroutines, exporterErrors, unknownSourceEvents, sourceChurn, pushFailures, pushLastSuccess, influxFailures, listening)
+	mustRegister(outputConnectTimes, outputEvents, apiSupported, observedScrapeInterval)
+	mustRegister(modulePathInfo, configReloads, configLastReload, loadDuration)
+	mustRegister(frontendAvailableGauge)
+	frontendAvailableGauge.Set(boolMetric(frontendAvailable))
+	if frontendAvailable {
+		mustRegister(lastSceneChange, lastStreamingStopCode, lastRecordingStopCode, stopCodeInfo)
+	}
+	mustRegister(backgroundHealth, tlsEnabled, tlsCertExpiry)
+	if activeConfig.PeakHistogram {
+		peakHistogram = newPeakHistogram(activeConfig.PeakHistogramBuckets)
+		mustRegister(peakHistogram)
+	}
+	// registrations keeps its value when it's unregistered, so it counts every load.
+	mustRegister(registrations)
+	registrations.Inc()
+}
+
+// unregisterMetrics unregisters everything registerMetrics registered.
+func unregisterMetrics() {
+	for _, c := range registeredCollectors {
+		prometheus.Unregister(c)
+	}
+	registeredCollectors = nil
+	peakHistogram = nil
+	descNames = map[*prometheus.Desc]string{}
 }
 
 //export obs_module_load
 func obs_module_load() C.bool {
+	defer observeLoadDuration(time.Now())
 	slog.SetDefault(slog.New(&OBSHandler{}))
+	// This is still set if we've been unloaded and loaded again.
+	shuttingDown.Store(false)
+	applyConfig(loadConfig())
+	detectStartupModes()
+	frontendAvailable = probeFrontend()
+	if !frontendAvailable {
+		slog.Warn("OBS's frontend isn't running; not exporting frontend metrics")
+	}
 	registerMetrics()
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "You have reached obs-studio-exporter. Please leave a message after the beep.")
-	})
-	http.Handle("/metrics", promhttp.Handler())
-	go func() {
-		for port := 9407; port < 9500; port++ {
-			slog.Info("Trying to listen for HTTP...", "port", port)
-			err := http.ListenAndServe(fmt.Sprintf(":%d", port), nil)
-			slog.Error("http.ListenAndServe failed", "port", port, "err", err)
+	if !frontendAvailable {
+		// There's no event to say OBS has finished loading, and nothing to wait for.
+		obsLoaded.Store(true)
+	}
+	checkAPIVersion()
+	loadServiceAPI()
+	recordModulePath()
+	registerFrontendCallbacks()
+	serveMux = newServeMux()
+	if len(activeConfig.Listeners) > 0 {
+		for _, l := range activeConfig.Listeners {
+			startListener(l)
 		}
-		// Don't crash OBS because we couldn't listen on the port.
-	}()
+	} else if activeConfig.TLSCertFile != "" {
+		tlsConfig, err := loadTLSConfig(activeConfig.TLSCertFile, activeConfig.TLSKeyFile, activeConfig.TLSClientCAFile)
+		if err != nil {
+			// Don't fall back to serving plain HTTP if HTTPS was asked for.
+			countError(errorConfigParse)
+			slog.Error("failed to load TLS config, not serving HTTP", "err", err)
+		} else {
+			serverTLSConfig = tlsConfig
+			listenHTTP()
+		}
+	} else {
+		listenHTTP()
+	}
+	// The goroutines use the config as it is now, since their settings can't be reloaded.
+	cfg := activeConfig
+	if cfg.PushgatewayURL != "" {
+		startBackground("pusher", func(ctx context.Context) {
+			runPusher(ctx, cfg.PushgatewayURL, cfg.PushInterval)
+		})
+	}
+	if cfg.OTLPEndpoint != "" {
+		startBackground("otlp", func(ctx context.Context) {
+			runOTLPExporter(ctx, cfg.OTLPEndpoint, cfg.OTLPInterval)
+		})
+	}
+	if cfg.InfluxURL != "" && cfg.InfluxBucket != "" {
+		startBackground("influx", func(ctx context.Context) {
+			runInfluxExporter(ctx, cfg.InfluxURL, cfg.InfluxBucket, cfg.InfluxOrg, cfg.InfluxToken, cfg.InfluxInterval)
+		})
+	}
+	if cfg.FilePath != "" {
+		startBackground("file", func(ctx context.Context) {
+			runFileExporter(ctx, cfg.FilePath, cfg.FileInterval, int64(cfg.FileMaxBytes))
+		})
+	}
+	if path := settingsFilePath(); path != "" {
+		startBackground("settings", func(ctx context.Context) {
+			watchSettings(ctx, path)
+		})
+	}
 	return true
 }
 
+func listenHTTP() {
+	if activeConfig.Port >= 0 {
+		ln, err := net.Listen("tcp", fmt.Sprintf(":%d", activeConfig.Port))
+		if err != nil {
+			// Don't crash OBS because we couldn't listen on the port.
+			countError(errorPortBind)
+			slog.Error("net.Listen failed", "port", activeConfig.Port, "err", err)
+		} else {
+			serveListener(ln, serverTLSConfig, nil)
+		}
+	} else {
+		// Bind before serving, so that we stop at the first free port and keep its listener
+		// in servers, where shutdownServers can close it.
+		for port := firstScanPort; port <= lastScanPort; port++ {
+			slog.Info("Trying to listen for HTTP...", "port", port)
+			ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+			if err != nil {
+				slog.Info("net.Listen failed, trying the next port", "port", port, "err", err)
+				continue
+			}
+			serveListener(ln, serverTLSConfig, nil)
+			return
+		}
+		// Don't crash OBS because we couldn't listen on any port.
+		countError(errorPortBind)
+		slog.Error("no free port to listen for HTTP on", "first", firstScanPort, "last", lastScanPort)
+	}
+}
+
+//export obs_module_unload
+func obs_module_unload() {
+	beginShutdown()
+	unregisterFrontendCallbacks()
+	shutdownServers(currentConfig().ShutdownTimeout)
+	stopBackground()
+	unregisterMetrics()
+	if activeMetricCollector != nil {
+		activeMetricCollector.close()
+	}
+	unloadServiceAPI()
+}
+
 //export mc_enum_sources_cb_go
-func mc_enum_sources_cb_go(f unsafe.Pointer, s *C.obs_source_t) C.bool {
+func mc_enum_sources_cb_go(f unsafe.Pointer, s *C.obs_source_t) (ret C.bool) {
+	defer recoverCollectPanic(&ret)
 	return activeMetricCollector.enumSourcesCB(f, s)
 }
 
 //export mc_enum_outputs_cb_go
-func mc_enum_outputs_cb_go(f unsafe.Pointer, s *C.obs_output_t) C.bool {
+func mc_enum_outputs_cb_go(f unsafe.Pointer, s *C.obs_output_t) (ret C.bool) {
+	defer recoverCollectPanic(&ret)
 	return activeMetricCollector.enumOutputsCB(f, s)
 }
 
 //export mc_enum_encoders_cb_go
-func mc_enum_encoders_cb_go(f unsafe.Pointer, s *C.obs_encoder_t) C.bool {
+func mc_enum_encoders_cb_go(f unsafe.Pointer, s *C.obs_encoder_t) (ret C.bool) {
+	defer recoverCollectPanic(&ret)
 	return activeMetricCollector.enumEncodersCB(f, s)
 }
 
@@ -469,29 +1840,52 @@ func genSlice(inp unsafe.Pointer) []float64 {
 	return out
 }
 
+func isClipping(peak float64) bool {
+	return peak >= clippingThresholdDBFS
+}
+
 //export mc_volmeter_updated_go
 func mc_volmeter_updated_go(f unsafe.Pointer, magnitude, peak, inputPeak unsafe.Pointer) {
-	id := C.GoString((*C.char)(f))
+	activeMetricCollector.volmeterUpdated(C.GoString((*C.char)(f)), genSlice(magnitude), genSlice(peak), genSlice(inputPeak))
+}
 
-	activeMetricCollector.mu.Lock()
-	src, ok := activeMetricCollector.sources[id]
+// volmeterUpdated records the levels from a source's volmeter callback, on OBS's audio thread.
+func (c *MetricCollector) volmeterUpdated(uuid string, magnitude, peak, inputPeak []float64) {
+	c.mu.Lock()
+	src, ok := c.sources[uuid]
 	if !ok {
-		slog.Debug("unknown source in mc_volmeter_updated_go", "source_id", id)
-		activeMetricCollector.mu.Unlock()
+		unknownSource("mc_volmeter_updated_go", uuid, time.Now())
+		c.mu.Unlock()
 		return
 	}
-	activeMetricCollector.mu.Unlock()
+	name := src.Name
+	c.mu.Unlock()
 
+	// Read the config before taking src.mu, which Collect holds while it snapshots the source.
+	cfg := currentConfig()
 	src.mu.Lock()
 	defer src.mu.Unlock()
 
-	omagnitude := genSlice(magnitude)
-	opeak := genSlice(peak)
-	oinputPeak := genSlice(inputPeak)
-	for ch := 0; ch < src.Channels; ch++ {
-		src.Magnitude[ch][src.Pos] = omagnitude[ch]
-		src.Peak[ch][src.Pos] = opeak[ch]
-		src.InputPeak[ch][src.Pos] = oinputPeak[ch]
+	src.recordLevels(magnitude, peak, inputPeak, time.Now(), cfg)
+	if peakHistogram != nil && src.Channels > 0 {
+		peakHistogram.WithLabelValues(src.ID, name).Observe(histogramPeak(peak[:src.Channels], cfg.PeakHistogramBuckets[0]))
+	}
+}
+
+// recordLevels adds a volmeter update to the source's circular buffers and per-channel counters.
+// It must be called with s.mu held.
+func (s *Source) recordLevels(magnitude, peak, inputPeak []float64, now time.Time, cfg *Config) {
+	for ch := 0; ch < s.Channels; ch++ {
+		s.Magnitude[ch][s.Pos] = magnitude[ch]
+		s.Peak[ch][s.Pos] = peak[ch]
+		s.InputPeak[ch][s.Pos] = inputPeak[ch]
+		if isClipping(peak[ch]) {
+			s.Clipping[ch]++
+		}
+		s.SessionPeak[ch] = math.Max(s.SessionPeak[ch], peak[ch])
+		s.PeakHold[ch] = s.PeakHold[ch].update(peak[ch], now, cfg.PeakHold, cfg.PeakDecay)
 	}
-	src.Pos = (src.Pos + 1) % circBufSamples
+	s.SampleTimes[s.Pos] = now
+	s.VolMeterUpdates++
+	s.Pos = (s.Pos + 1) % circBufSamples
 }
@@ -0,0 +1,673 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// logRecorder is a slog.Handler which keeps the messages it's given.
+type logRecorder struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *logRecorder) Enabled(context.Context, slog.Level) bool { return true }
+func (r *logRecorder) WithAttrs([]slog.Attr) slog.Handler       { return r }
+func (r *logRecorder) WithGroup(string) slog.Handler            { return r }
+
+func (r *logRecorder) Handle(_ context.Context, rec slog.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, rec.Message)
+	return nil
+}
+
+func (r *logRecorder) Messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.messages...)
+}
+
+// recordLogs sends everything logged to the default logger to a logRecorder until the test ends.
+func recordLogs(t *testing.T) *logRecorder {
+	t.Helper()
+	old := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(old) })
+	r := &logRecorder{}
+	slog.SetDefault(slog.New(r))
+	return r
+}
+
+type emittedMetric struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	// Timestamp is zero unless the metric has one.
+	Timestamp time.Time
+}
+
+// newTestCollector returns a collector using the default config, which is restored when the test ends.
+func newTestCollector(t *testing.T) *MetricCollector {
+	t.Helper()
+	return newTestCollectorWithConfig(t, defaultConfig())
+}
+
+// newTestCollectorWithConfig returns a collector using cfg, until the test ends.
+func newTestCollectorWithConfig(t *testing.T, cfg *Config) *MetricCollector {
+	t.Helper()
+	old := activeConfig
+	t.Cleanup(func() { applyConfig(old) })
+	applyConfig(cfg)
+	return NewMetricCollector()
+}
+
+// emitSnapshot returns the metrics c emits for snap.
+func emitSnapshot(t *testing.T, c *MetricCollector, snap *collectorSnapshot) []emittedMetric {
+	t.Helper()
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.emit(ch, snap, currentConfig())
+		close(ch)
+	}()
+	var ms []emittedMetric
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatalf("writing %v: %v", m.Desc(), err)
+		}
+		em := emittedMetric{Name: descNames[m.Desc()], Labels: map[string]string{}}
+		for _, l := range pb.GetLabel() {
+			em.Labels[l.GetName()] = l.GetValue()
+		}
+		if pb.TimestampMs != nil {
+			em.Timestamp = time.Unix(0, pb.GetTimestampMs()*int64(time.Millisecond))
+		}
+		switch {
+		case pb.Gauge != nil:
+			em.Value = pb.GetGauge().GetValue()
+		case pb.Counter != nil:
+			em.Value = pb.GetCounter().GetValue()
+		case pb.Untyped != nil:
+			em.Value = pb.GetUntyped().GetValue()
+		}
+		ms = append(ms, em)
+	}
+	return ms
+}
+
+// findMetric returns the metric called name whose labels include labels.
+func findMetric(ms []emittedMetric, name string, labels map[string]string) (emittedMetric, bool) {
+next:
+	for _, m := range ms {
+		if m.Name != name {
+			continue
+		}
+		for k, v := range labels {
+			if m.Labels[k] != v {
+				continue next
+			}
+		}
+		return m, true
+	}
+	return emittedMetric{}, false
+}
+
+func TestEmitSourceBalance(t *testing.T) {
+	c := newTestCollector(t)
+	snap := &collectorSnapshot{Up: true, Sources: []sourceSnapshot{
+		{ID: "wasapi_input_capture", Name: "Mic", IsAudio: true, Balance: 0.25},
+		{ID: "image_source", Name: "Logo", IsVideo: true},
+	}}
+
+	ms := emitSnapshot(t, c, snap)
+	m, ok := findMetric(ms, "obs_source_balance", map[string]string{"source_name": "Mic"})
+	if !ok {
+		t.Fatal("no obs_source_balance for the audio source")
+	}
+	if m.Value != 0.25 {
+		t.Errorf("obs_source_balance = %v, want 0.25", m.Value)
+	}
+	if _, ok := findMetric(ms, "obs_source_balance", map[string]string{"source_name": "Logo"}); ok {
+		t.Error("obs_source_balance exported for a source without audio")
+	}
+}
+
+func TestRecordLevelsCountsClipping(t *testing.T) {
+	s := &Source{}
+	s.resizeChannels(2)
+	cfg := defaultConfig()
+	now := time.Now()
+	for _, peaks := range [][]float64{
+		{-12, -3},
+		{0, -1},
+		{-0.5, 0.2},
+		{-20, -20},
+		{1.5, 0},
+	} {
+		s.recordLevels(peaks, peaks, peaks, now, cfg)
+	}
+	for ch, want := range []uint64{2, 2} {
+		if s.Clipping[ch] != want {
+			t.Errorf("channel %d clipped %d times, want %d", ch, s.Clipping[ch], want)
+		}
+	}
+}
+
+func TestRecordLevelsCountsUpdates(t *testing.T) {
+	s := &Source{}
+	s.resizeChannels(1)
+	cfg := defaultConfig()
+	levels := []float64{-20}
+	for n := 1; n <= 3; n++ {
+		s.recordLevels(levels, levels, levels, time.Now(), cfg)
+		if got := s.snapshotMeter(cfg).VolMeterUpdates; got != uint64(n) {
+			t.Errorf("after %d callbacks, VolMeterUpdates = %d", n, got)
+		}
+	}
+}
+
+func TestCollectStopsWhenShuttingDown(t *testing.T) {
+	defer shuttingDown.Store(shuttingDown.Load())
+	shuttingDown.Store(true)
+	c := newTestCollector(t)
+
+	// This would crash if it called into OBS, which isn't there.
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	var names []string
+	for m := range ch {
+		names = append(names, descNames[m.Desc()])
+		if m.Desc() == c.Up {
+			var pb dto.Metric
+			if err := m.Write(&pb); err != nil {
+				t.Fatal(err)
+			}
+			if v := pb.GetGauge().GetValue(); v != 0 {
+				t.Errorf("obs_up = %v while shutting down, want 0", v)
+			}
+		}
+	}
+	if len(names) != 2 || names[0] != "obs_exporter_build_info" || names[1] != "obs_up" {
+		t.Errorf("collected %v while shutting down, want only obs_exporter_build_info and obs_up", names)
+	}
+}
+
+func TestEmitAudioMonitoringDevice(t *testing.T) {
+	c := newTestCollector(t)
+	snap := &collectorSnapshot{Up: true}
+	snap.Global.MonitoringDeviceName = "Headphones (USB Audio)"
+	snap.Global.MonitoringDeviceID = "{0.0.0.00000000}.{1234}"
+
+	ms := emitSnapshot(t, c, snap)
+	want := map[string]string{"name": "Headphones (USB Audio)", "id": "{0.0.0.00000000}.{1234}"}
+	if _, ok := findMetric(ms, "obs_audio_monitoring_device_info", want); !ok {
+		t.Errorf("no obs_audio_monitoring_device_info with labels %v", want)
+	}
+}
+
+func TestEmitMemoryAllocations(t *testing.T) {
+	c := newTestCollector(t)
+	snap := &collectorSnapshot{Up: true}
+	snap.Global.MemoryAllocations = 12345
+
+	m, ok := findMetric(emitSnapshot(t, c, snap), "obs_memory_allocations", nil)
+	if !ok {
+		t.Fatal("no obs_memory_allocations")
+	}
+	if m.Value != 12345 {
+		t.Errorf("obs_memory_allocations = %v, want 12345", m.Value)
+	}
+}
+
+// fullSnapshot returns a snapshot with everything filled in, so that emitting it covers every metric.
+func fullSnapshot() *collectorSnapshot {
+	now := time.Now()
+	channel := channelSnapshot{
+		Magnitude: -20, Peak: -10, InputPeak: -8,
+		MagnitudeTime: now, PeakTime: now, InputPeakTime: now,
+		Clipping: 1, SessionPeak: -2, PeakHold: -6,
+	}
+	snap := &collectorSnapshot{
+		Up: true,
+		Global: globalSnapshot{
+			ActiveFPS: 60, TargetFPS: 60,
+			Canvas: canvasSnapshot{BaseWidth: 1920, BaseHeight: 1080, Colorspace: "709", Range: "partial"}, HasCanvas: true,
+			HasFrontend: true, OutputMode: "Advanced", StudioMode: true,
+			ProgramScene:    frontendSceneInfo{Name: "Live", Width: 1920, Height: 1080},
+			PreviewScene:    frontendSceneInfo{Name: "BRB", Width: 1920, Height: 1080},
+			HasReplayBuffer: true, ReplayBufferLength: 30,
+			HasAudioInfo: true, AudioSampleRate: 48000, AudioSpeakers: 2,
+			WebSocketLoaded: true, WebSocketEnabled: true,
+		},
+		Sources: []sourceSnapshot{{
+			ID: "wasapi_input_capture", UUID: "uuid-mic", Name: "Mic",
+			IsAudio: true, Balance: 0.5, Mixers: 0b11,
+			AudioFilters:     []audioFilterSnapshot{{ID: "gain_filter", Name: "Gain", Params: map[string]float64{"db": 3}}},
+			HasGlobalChannel: true, GlobalChannel: 3,
+			Meter: &sourceMeterSnapshot{VolumeChanges: 1, VolMeterUpdates: 10, Channels: []channelSnapshot{channel, channel}},
+		}, {
+			ID: "window_capture", UUID: "uuid-game", Name: "Game",
+			IsVideo: true, Width: 1920, Height: 1080, CaptureTarget: "Game:Game:game.exe",
+		}},
+		Outputs: []outputSnapshot{{
+			ID: "rtmp_output", Name: "simple_stream", DisplayName: "RTMP Output", Kind: outputKindStreaming, ServerHost: "live.twitch.tv",
+			Active: true, TotalFrames: 1000, DroppedFrames: 3, Reconnecting: true, HasReconnectDelay: true, ReconnectDelayRemaining: 5,
+			HasVideo: true, Rescaling: true, ScaleType: "bicubic",
+			EncodesVideo: true, EncodesAudio: true, HasVideoEncoder: true, HasAudioEncoder: true,
+			HasBitrates: true, VideoKbps: 6000, AudioKbps: 160, DynamicBitrate: true, HasCurrentBitrate: true, CurrentKbps: 5500,
+		}},
+		Encoders: []encoderSnapshot{{
+			ID: "obs_x264", Name: "simple_video_stream", DisplayName: "x264", Codec: "h264",
+			Active: true, HasOutputDrops: true, Reconfigurable: true, HasFPSDivisor: true, FPSDivisor: 1,
+			Settings: encoderSettings{Preset: "veryfast", Bitrate: 6000, GPU: -1}, HasCPUPercent: true, CPUPercent: 25,
+		}},
+		Scenes:          []sceneSnapshot{{Name: "Live", UUID: "uuid-live", Items: 2, HasFrontend: true, Program: true}},
+		Groups:          []groupSnapshot{{Name: "Cameras", Members: 2}},
+		SceneReferences: map[string]int{"uuid-mic": 1, "uuid-game": 1},
+		ProfileEncoders: []profileEncoderSnapshot{{Profile: "Untitled", EncoderID: "obs_x264", Bitrate: 6000}},
+	}
+	return snap
+}
+
+func TestEmitDoesNotCallOBS(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.AudioTracks = true
+	cfg.SampleTimestamps = true
+	cfg.Groups = true
+	cfg.SceneReferences = true
+	cfg.ProfileEncoders = true
+	c := newTestCollectorWithConfig(t, cfg)
+
+	// OBS isn't there in tests, so emit would crash if it called into it.
+	ms := emitSnapshot(t, c, fullSnapshot())
+
+	names := map[string]bool{}
+	for _, m := range ms {
+		names[m.Name] = true
+	}
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(descs)
+		close(descs)
+	}()
+	var missing []string
+	for d := range descs {
+		if name, ok := descNames[d]; ok && !names[name] && name != "obs_exporter_build_info" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		t.Errorf("emitting a full snapshot didn't export %v", missing)
+	}
+}
+
+func TestRecordLevelsSessionPeakOnlyIncreases(t *testing.T) {
+	s := &Source{}
+	s.resizeChannels(1)
+	cfg := defaultConfig()
+	now := time.Now()
+	want := math.Inf(-1)
+	for _, peak := range []float64{-30, -12, -40, -6, -60, -6.5, -3} {
+		s.recordLevels([]float64{peak}, []float64{peak}, []float64{peak}, now, cfg)
+		want = math.Max(want, peak)
+		if s.SessionPeak[0] != want {
+			t.Errorf("after a peak of %v, SessionPeak = %v, want %v", peak, s.SessionPeak[0], want)
+		}
+	}
+	// It outlasts the circular buffer, which has long forgotten the loudest peak.
+	for i := 0; i < circBufSamples; i++ {
+		s.recordLevels([]float64{-50}, []float64{-50}, []float64{-50}, now, cfg)
+	}
+	if got := s.snapshotMeter(cfg).Channels[0]; got.SessionPeak != -3 || got.Peak != -50 {
+		t.Errorf("snapshot has SessionPeak %v and Peak %v, want -3 and -50", got.SessionPeak, got.Peak)
+	}
+}
+
+func TestEmitSampleTimestamps(t *testing.T) {
+	sampled := time.Now().Add(-2 * time.Second).Truncate(time.Millisecond)
+	snap := &collectorSnapshot{Up: true, Sources: []sourceSnapshot{{
+		ID: "wasapi_input_capture", Name: "Mic", IsAudio: true,
+		Meter: &sourceMeterSnapshot{Channels: []channelSnapshot{{Peak: -6, PeakTime: sampled, Magnitude: -20}}},
+	}}}
+
+	for _, enabled := range []bool{false, true} {
+		cfg := defaultConfig()
+		cfg.SampleTimestamps = enabled
+		ms := emitSnapshot(t, newTestCollectorWithConfig(t, cfg), snap)
+
+		peak, ok := findMetric(ms, "obs_source_channel_peak", nil)
+		if !ok {
+			t.Fatal("no obs_source_channel_peak")
+		}
+		want := time.Time{}
+		if enabled {
+			want = sampled
+		}
+		if !peak.Timestamp.Equal(want) {
+			t.Errorf("with sample timestamps %v, obs_source_channel_peak has timestamp %v, want %v", enabled, peak.Timestamp, want)
+		}
+		// There's no time to attach if nothing was sampled.
+		if magnitude, _ := findMetric(ms, "obs_source_channel_magnitude", nil); !magnitude.Timestamp.IsZero() {
+			t.Errorf("obs_source_channel_magnitude has timestamp %v without a sample time", magnitude.Timestamp)
+		}
+	}
+}
+
+func TestEmitCombinedChannels(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.CombineChannels = true
+	c := newTestCollectorWithConfig(t, cfg)
+	snap := &collectorSnapshot{Up: true, Sources: []sourceSnapshot{{
+		ID: "wasapi_input_capture", Name: "Mic", IsAudio: true,
+		Meter: &sourceMeterSnapshot{Channels: []channelSnapshot{
+			{Magnitude: -30, Peak: -3, InputPeak: -12, Clipping: 1},
+			{Magnitude: -18, Peak: -9, InputPeak: -2, Clipping: 2},
+		}},
+	}}}
+
+	ms := emitSnapshot(t, c, snap)
+	for name, want := range map[string]float64{
+		"obs_source_channel_magnitude":      -18,
+		"obs_source_channel_peak":           -3,
+		"obs_source_input_peak":             -2,
+		"obs_source_channel_clipping_total": 3,
+	} {
+		var got []emittedMetric
+		for _, m := range ms {
+			if m.Name == name {
+				got = append(got, m)
+			}
+		}
+		if len(got) != 1 {
+			t.Errorf("got %d %s series, want 1", len(got), name)
+			continue
+		}
+		if _, ok := got[0].Labels["channel_id"]; ok {
+			t.Errorf("%s has a channel_id label in combined mode", name)
+		}
+		if got[0].Value != want {
+			t.Errorf("%s = %v, want %v", name, got[0].Value, want)
+		}
+	}
+}
+
+func TestSourceLimit(t *testing.T) {
+	l := newSourceLimit(2)
+	for _, step := range []struct {
+		uuid        string
+		track, more bool
+	}{
+		{"a", true, true},
+		// A source can be enumerated more than once, but only counts once.
+		{"a", false, true},
+		{"b", true, true},
+		{"c", false, false},
+	} {
+		if track, more := l.admit(step.uuid); track != step.track || more != step.more {
+			t.Errorf("admit(%q) = %v, %v, want %v, %v", step.uuid, track, more, step.track, step.more)
+		}
+	}
+	if !l.truncated {
+		t.Error("exceeding the limit didn't set truncated")
+	}
+	if len(l.seen) != 2 || l.seen["c"] {
+		t.Errorf("tracked %v, want just a and b", l.seen)
+	}
+
+	unlimited := newSourceLimit(0)
+	for _, uuid := range []string{"a", "b", "c"} {
+		if track, _ := unlimited.admit(uuid); !track {
+			t.Errorf("admit(%q) with no limit didn't track it", uuid)
+		}
+	}
+	if unlimited.truncated {
+		t.Error("truncated set with no limit")
+	}
+}
+
+func TestEmitSourcesTruncated(t *testing.T) {
+	c := newTestCollector(t)
+	for _, truncated := range []bool{false, true} {
+		ms := emitSnapshot(t, c, &collectorSnapshot{Up: true, SourcesTruncated: truncated})
+		m, ok := findMetric(ms, "obs_exporter_sources_truncated", nil)
+		if !ok || m.Value != boolMetric(truncated) {
+			t.Errorf("with truncated %v, obs_exporter_sources_truncated = %v (emitted %v), want %v", truncated, m.Value, ok, boolMetric(truncated))
+		}
+	}
+}
+
+func TestRefreshSourceNames(t *testing.T) {
+	c := newTestCollector(t)
+	src := &Source{ID: "wasapi_input_capture", UUID: "mic-uuid", Name: "Mic"}
+	c.sources[src.UUID] = src
+
+	snaps := []sourceSnapshot{{
+		ID: src.ID, UUID: src.UUID, Name: "Voice", IsAudio: true,
+		Meter: &sourceMeterSnapshot{Channels: []channelSnapshot{{Peak: -6}}},
+	}}
+	c.refreshSourceNames(snaps)
+	if got := c.sources[src.UUID]; got != src || got.Name != "Voice" {
+		t.Errorf("after renaming, the volmeter callback finds %+v, want the same source named Voice", got)
+	}
+
+	ms := emitSnapshot(t, c, &collectorSnapshot{Up: true, Sources: snaps})
+	if _, ok := findMetric(ms, "obs_source_channel_peak", map[string]string{"source_name": "Voice"}); !ok {
+		t.Error("no obs_source_channel_peak for the new name")
+	}
+	if _, ok := findMetric(ms, "obs_source_channel_peak", map[string]string{"source_name": "Mic"}); ok {
+		t.Error("obs_source_channel_peak still emitted for the old name")
+	}
+}
+
+func TestHelpOverrides(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.HelpOverrides = map[string]string{"obs_up": "Ob OBS Metriken gesammelt werden."}
+	c := newTestCollectorWithConfig(t, cfg)
+
+	if got := c.Up.String(); !strings.Contains(got, `help: "Ob OBS Metriken gesammelt werden."`) {
+		t.Errorf("overridden descriptor is %s, want the custom help", got)
+	}
+	if got := c.ActiveFPS.String(); !strings.Contains(got, `help: "Active frames per second."`) {
+		t.Errorf("descriptor without an override is %s, want the default help", got)
+	}
+}
+
+func TestSourceLatency(t *testing.T) {
+	ms := int64(time.Millisecond)
+	for _, tc := range []struct {
+		syncOffset, buffering int64
+		want                  float64
+	}{
+		{0, 0, 0},
+		{200 * ms, 0, float64(200 * ms)},
+		{200 * ms, 40 * ms, float64(240 * ms)},
+		{-50 * ms, 20 * ms, float64(-30 * ms)},
+	} {
+		if got := sourceLatencyNS(tc.syncOffset, tc.buffering); got != tc.want {
+			t.Errorf("sourceLatencyNS(%d, %d) = %v, want %v", tc.syncOffset, tc.buffering, got, tc.want)
+		}
+	}
+}
+
+func TestEmitEncoderInstances(t *testing.T) {
+	c := newTestCollector(t)
+	snap := &collectorSnapshot{Up: true, Encoders: []encoderSnapshot{
+		{ID: "obs_x264", Name: "streaming_h264"},
+		{ID: "obs_x264", Name: "recording_h264"},
+		{ID: "ffmpeg_aac", Name: "simple_aac", IsAudio: true},
+	}}
+	ms := emitSnapshot(t, c, snap)
+
+	for id, want := range map[string]float64{"obs_x264": 2, "ffmpeg_aac": 1} {
+		if m, ok := findMetric(ms, "obs_encoder_instances", map[string]string{"encoder_id": id}); !ok || m.Value != want {
+			t.Errorf("obs_encoder_instances{encoder_id=%q} = %v (emitted %v), want %v", id, m.Value, ok, want)
+		}
+	}
+}
+
+func TestSourceChurn(t *testing.T) {
+	c := newTestCollector(t)
+	added := testutil.ToFloat64(sourceChurn.WithLabelValues("added"))
+	removed := testutil.ToFloat64(sourceChurn.WithLabelValues("removed"))
+	scrape := func(uuids ...string) {
+		seen := map[string]bool{}
+		for _, uuid := range uuids {
+			seen[uuid] = true
+			if _, ok := c.sources[uuid]; !ok {
+				c.trackSource(&Source{ID: "wasapi_input_capture", UUID: uuid, Name: uuid})
+			}
+		}
+		c.pruneSources(seen)
+	}
+
+	scrape("mic", "desktop")
+	scrape("mic", "camera")
+	scrape("mic", "camera")
+
+	if got := testutil.ToFloat64(sourceChurn.WithLabelValues("added")) - added; got != 3 {
+		t.Errorf("source_churn_total{direction=\"added\"} went up by %v, want 3", got)
+	}
+	if got := testutil.ToFloat64(sourceChurn.WithLabelValues("removed")) - removed; got != 1 {
+		t.Errorf("source_churn_total{direction=\"removed\"} went up by %v, want 1", got)
+	}
+	if _, ok := c.sources["desktop"]; ok || len(c.sources) != 2 {
+		t.Errorf("tracking %d sources after desktop went away, want mic and camera", len(c.sources))
+	}
+}
+
+func TestAudioBufferBytes(t *testing.T) {
+	c := newTestCollector(t)
+	if got := c.audioBufferBytes(); got != 0 {
+		t.Errorf("audioBufferBytes with no sources = %d, want 0", got)
+	}
+	// Each channel has a magnitude, peak and input peak buffer of circBufSamples float64s.
+	perChannel := 3 * circBufSamples * 8
+	for _, tc := range []struct {
+		uuid     string
+		channels int
+		want     int
+	}{
+		{"mic", 1, 1 * perChannel},
+		{"desktop", 2, 3 * perChannel},
+		{"surround", 8, 11 * perChannel},
+	} {
+		s := &Source{UUID: tc.uuid}
+		s.resizeChannels(tc.channels)
+		c.sources[tc.uuid] = s
+		if got := c.audioBufferBytes(); got != tc.want {
+			t.Errorf("after adding a %d channel source, audioBufferBytes = %d, want %d", tc.channels, got, tc.want)
+		}
+	}
+}
+
+func TestClampChannels(t *testing.T) {
+	for in, want := range map[int]int{-1: 0, 0: 0, 1: 1, 6: 6, maxAudioChannels: maxAudioChannels, maxAudioChannels + 1: maxAudioChannels} {
+		if got := clampChannels(in); got != want {
+			t.Errorf("clampChannels(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestMonoSourceEmitsOneChannel(t *testing.T) {
+	c := newTestCollector(t)
+	cfg := defaultConfig()
+	s := &Source{ID: "wasapi_input_capture", Name: "Mic"}
+	s.resizeChannels(1)
+	// The volmeter callback always passes MAX_AUDIO_CHANNELS levels, whatever the source has.
+	levels := make([]float64, maxAudioChannels)
+	for ch := range levels {
+		levels[ch] = -12
+	}
+	s.recordLevels(levels, levels, levels, time.Now(), cfg)
+
+	snap := &collectorSnapshot{Up: true, Sources: []sourceSnapshot{{ID: s.ID, Name: s.Name, IsAudio: true, Meter: s.snapshotMeter(cfg)}}}
+	ms := emitSnapshot(t, c, snap)
+	if _, ok := findMetric(ms, "obs_source_channel_peak", map[string]string{"source_name": "Mic", "channel_id": "0"}); !ok {
+		t.Error("no peak for channel 0 of a mono source")
+	}
+	if _, ok := findMetric(ms, "obs_source_channel_peak", map[string]string{"source_name": "Mic", "channel_id": "1"}); ok {
+		t.Error("peak emitted for channel 1 of a mono source")
+	}
+
+	// When the source switches to 5.1, every channel starts from silence.
+	s.resizeChannels(6)
+	meter := s.snapshotMeter(cfg)
+	if len(meter.Channels) != 6 {
+		t.Fatalf("%d channels after resizing to 5.1, want 6", len(meter.Channels))
+	}
+	for ch, cs := range meter.Channels {
+		if !math.IsInf(cs.Peak, -1) {
+			t.Errorf("channel %d peak = %v after resizing, want -Inf", ch, cs.Peak)
+		}
+	}
+}
+
+func TestReloadConfigDuringCollect(t *testing.T) {
+	c := newTestCollector(t)
+	src := &Source{ID: "wasapi_input_capture", UUID: "uuid-mic", Name: "Mic"}
+	src.resizeChannels(2)
+	c.sources[src.UUID] = src
+	// Like snapshot, this takes the source's lock while collecting.
+	snapshot := func(cfg *Config) *collectorSnapshot {
+		return &collectorSnapshot{Up: true, Sources: []sourceSnapshot{{ID: src.ID, UUID: src.UUID, Name: src.Name, IsAudio: true, Meter: src.snapshotMeter(cfg)}}}
+	}
+	levels := make([]float64, maxAudioChannels)
+
+	const rounds = 20000
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			reloadConfig()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			c.volmeterUpdated(src.UUID, levels, levels, levels)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			ch := make(chan prometheus.Metric, 64)
+			go func() {
+				for range ch {
+				}
+			}()
+			c.collect(ch, snapshot)
+			close(ch)
+		}
+	}()
+
+	// If the locks are taken in an order that can deadlock, this hangs until go test's -timeout
+	// fails it with every goroutine's stack.
+	wg.Wait()
+}
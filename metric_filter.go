@@ -0,0 +1,90 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// descNames maps each of the collector's descriptors to its metric name, since
+// prometheus.Desc doesn't expose it. It's filled by NewMetricCollector and emptied by
+// unregisterMetrics, so it only holds the descriptors of the collector that's registered.
+var descNames = map[*prometheus.Desc]string{}
+
+// metricEnabled reports whether the config leaves the metric called name turned on.
+func metricEnabled(enabled map[string]bool, name string) bool {
+	on, ok := enabled[name]
+	return !ok || on
+}
+
+// metricSink sends a scrape's metrics to ch, skipping the ones turned off in cfg
+// before they're built.
+type metricSink struct {
+	ch  chan<- prometheus.Metric
+	cfg *Config
+}
+
+func (s metricSink) enabled(d *prometheus.Desc) bool {
+	return metricEnabled(s.cfg.EnabledMetrics, descNames[d])
+}
+
+// send sends a metric for d, if it's enabled.
+func (s metricSink) send(d *prometheus.Desc, t prometheus.ValueType, v float64, labels ...string) {
+	if s.enabled(d) {
+		s.ch <- prometheus.MustNewConstMetric(d, t, v, labels...)
+	}
+}
+
+// sendAt is send for a windowed sample taken at ts, which is attached to the metric if
+// that's enabled.
+func (s metricSink) sendAt(ts time.Time, d *prometheus.Desc, t prometheus.ValueType, v float64, labels ...string) {
+	if !s.enabled(d) {
+		return
+	}
+	m := prometheus.MustNewConstMetric(d, t, v, labels...)
+	if s.cfg.SampleTimestamps && !ts.IsZero() {
+		m = prometheus.NewMetricWithTimestamp(ts, m)
+	}
+	s.ch <- m
+}
+
+// filteredGatherer gathers from its Gatherer, dropping the metrics turned off in the config.
+// MetricCollector skips them itself, so this is for the collectors client_golang keeps
+// up to date as things happen.
+type filteredGatherer struct {
+	prometheus.Gatherer
+}
+
+func (g filteredGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.Gatherer.Gather()
+	enabled := currentConfig().EnabledMetrics
+	if len(enabled) == 0 {
+		return mfs, err
+	}
+	kept := mfs[:0]
+	for _, mf := range mfs {
+		if metricEnabled(enabled, mf.GetName()) {
+			kept = append(kept, mf)
+		}
+	}
+	return kept, err
+}
+
+// exportedMetrics is what every endpoint and exporter serves: everything that's registered,
+// less the metrics turned off in the config.
+var exportedMetrics prometheus.Gatherer = filteredGatherer{prometheus.DefaultGatherer}
@@ -0,0 +1,115 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// snapshotCollector collects a fixed snapshot through a MetricCollector, without calling into OBS.
+type snapshotCollector struct {
+	*MetricCollector
+	snap *collectorSnapshot
+}
+
+func (s snapshotCollector) Collect(ch chan<- prometheus.Metric) {
+	s.emit(ch, s.snap, currentConfig())
+}
+
+func TestFilteredGathererDropsDisabledMetrics(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.EnabledMetrics = map[string]bool{
+		"obs_source_input_peak":   false,
+		"obs_exporter_goroutines": false,
+		"obs_source_channel_peak": true,
+	}
+	c := newTestCollectorWithConfig(t, cfg)
+	snap := &collectorSnapshot{Up: true, Sources: []sourceSnapshot{{
+		ID: "wasapi_input_capture", Name: "Mic", IsAudio: true,
+		Meter: &sourceMeterSnapshot{Channels: []channelSnapshot{{Magnitude: -20, Peak: -6, InputPeak: -3}}},
+	}}}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(snapshotCollector{c, snap}, goroutines)
+
+	mfs, err := filteredGatherer{reg}.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, mf := range mfs {
+		got[mf.GetName()] = true
+	}
+	for _, name := range []string{"obs_source_input_peak", "obs_exporter_goroutines"} {
+		if got[name] {
+			t.Errorf("%s was gathered after being turned off", name)
+		}
+	}
+	for _, name := range []string{"obs_up", "obs_source_channel_magnitude", "obs_source_channel_peak"} {
+		if !got[name] {
+			t.Errorf("%s is missing, but only other metrics were turned off", name)
+		}
+	}
+}
+
+func TestEmitSkipsDisabledMetrics(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.EnabledMetrics = map[string]bool{"obs_source_input_peak": false, "obs_output_dropped_frames_total": false}
+	c := newTestCollectorWithConfig(t, cfg)
+
+	ms := emitSnapshot(t, c, fullSnapshot())
+	got := map[string]bool{}
+	for _, m := range ms {
+		got[m.Name] = true
+	}
+	for name := range cfg.EnabledMetrics {
+		if got[name] {
+			t.Errorf("%s was emitted after being turned off", name)
+		}
+	}
+	if !got["obs_source_channel_peak"] {
+		t.Error("obs_source_channel_peak is missing, but only other metrics were turned off")
+	}
+}
+
+func TestReloadEnablingMetricKeepsCollectorRegistered(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.EnabledMetrics = map[string]bool{"obs_source_input_peak": false}
+	c := newTestCollectorWithConfig(t, cfg)
+	snap := &collectorSnapshot{Up: true, Sources: []sourceSnapshot{{
+		ID: "wasapi_input_capture", Name: "Mic", IsAudio: true,
+		Meter: &sourceMeterSnapshot{Channels: []channelSnapshot{{Magnitude: -20, Peak: -6, InputPeak: -3}}},
+	}}}
+	sc := snapshotCollector{c, snap}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(sc)
+
+	applyConfig(defaultConfig())
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, mf := range mfs {
+		found = found || mf.GetName() == "obs_source_input_peak"
+	}
+	if !found {
+		t.Error("obs_source_input_peak isn't gathered after a reload turned it on")
+	}
+	if !reg.Unregister(sc) {
+		t.Error("the collector couldn't be unregistered after a reload changed which metrics are enabled")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <obs-module.h>
+*/
+import "C"
+
+import (
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var modulePathInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: exporterSubsystem,
+	Name:      "module_path_info",
+	Help:      "The file the exporter plugin was loaded from.",
+}, []string{"path"})
+
+// modulePath returns the path to the plugin's binary, or just its file name if
+// libobs doesn't know where it is. The strings belong to libobs.
+func modulePath(m *C.obs_module_t) string {
+	if m == nil {
+		return ""
+	}
+	return pickModulePath(C.GoString(C.obs_get_module_binary_path(m)), C.GoString(C.obs_get_module_file_name(m)))
+}
+
+func pickModulePath(binaryPath, fileName string) string {
+	if binaryPath != "" {
+		return binaryPath
+	}
+	return fileName
+}
+
+// recordModulePath exports where the plugin was loaded from, to track down copies installed in more than one place.
+func recordModulePath() {
+	setModulePath(modulePath(obsModulePointer))
+}
+
+func setModulePath(path string) {
+	if path == "" {
+		return
+	}
+	slog.Info("loaded from " + path)
+	modulePathInfo.WithLabelValues(path).Set(1)
+}
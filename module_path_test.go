@@ -0,0 +1,56 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPickModulePath(t *testing.T) {
+	for _, tc := range []struct {
+		binaryPath, fileName, want string
+	}{
+		{"/usr/lib/obs-plugins/obs_studio_exporter.so", "obs_studio_exporter.so", "/usr/lib/obs-plugins/obs_studio_exporter.so"},
+		{"", "obs_studio_exporter.so", "obs_studio_exporter.so"},
+		{"", "", ""},
+	} {
+		if got := pickModulePath(tc.binaryPath, tc.fileName); got != tc.want {
+			t.Errorf("pickModulePath(%q, %q) = %q, want %q", tc.binaryPath, tc.fileName, got, tc.want)
+		}
+	}
+}
+
+func TestModulePathNilModule(t *testing.T) {
+	if got := modulePath(nil); got != "" {
+		t.Errorf("modulePath(nil) = %q, want empty", got)
+	}
+}
+
+func TestSetModulePath(t *testing.T) {
+	t.Cleanup(modulePathInfo.Reset)
+	recordLogs(t)
+
+	setModulePath("")
+	if n := testutil.CollectAndCount(modulePathInfo); n != 0 {
+		t.Errorf("%d series exported for an unknown path", n)
+	}
+	const path = "/home/user/.config/obs-studio/plugins/obs_studio_exporter/bin/64bit/obs_studio_exporter.so"
+	setModulePath(path)
+	if got := testutil.ToFloat64(modulePathInfo.WithLabelValues(path)); got != 1 {
+		t.Errorf("module_path_info{path=%q} = %v, want 1", path, got)
+	}
+}
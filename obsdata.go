@@ -0,0 +1,128 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <stdlib.h>
+#include <obs.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"unsafe"
+)
+
+// obsDataJSON returns the JSON serialization of data and releases it.
+func obsDataJSON(data *C.obs_data_t) string {
+	if data == nil {
+		return ""
+	}
+	defer C.obs_data_release(data)
+	return C.GoString(C.obs_data_get_json(data))
+}
+
+// obsDataString returns the first non-empty string value, including defaults, of the given keys.
+func obsDataString(data *C.obs_data_t, keys ...string) string {
+	for _, key := range keys {
+		keyC := C.CString(key)
+		v := C.GoString(C.obs_data_get_string(data, keyC))
+		C.free(unsafe.Pointer(keyC))
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// obsDataInt returns the integer value, including defaults, of key.
+func obsDataInt(data *C.obs_data_t, key string) int {
+	keyC := C.CString(key)
+	defer C.free(unsafe.Pointer(keyC))
+	return int(C.obs_data_get_int(data, keyC))
+}
+
+// obsDataIntDefault returns the integer value, including defaults, of key, or def if key has no value at all.
+func obsDataIntDefault(data *C.obs_data_t, key string, def int) int {
+	keyC := C.CString(key)
+	defer C.free(unsafe.Pointer(keyC))
+	if !C.obs_data_has_user_value(data, keyC) && !C.obs_data_has_default_value(data, keyC) {
+		return def
+	}
+	return int(C.obs_data_get_int(data, keyC))
+}
+
+// obsDataBool returns the boolean value, including defaults, of key.
+func obsDataBool(data *C.obs_data_t, key string) bool {
+	keyC := C.CString(key)
+	defer C.free(unsafe.Pointer(keyC))
+	return bool(C.obs_data_get_bool(data, keyC))
+}
+
+// obsDataDouble returns the floating point value, including defaults, of key.
+func obsDataDouble(data *C.obs_data_t, key string) float64 {
+	keyC := C.CString(key)
+	defer C.free(unsafe.Pointer(keyC))
+	return float64(C.obs_data_get_double(data, keyC))
+}
+
+// settingsData reads values, including defaults, from a settings object. It's what the code that
+// picks apart OBS's settings objects is given, so that it can be tested without OBS.
+type settingsData interface {
+	String(keys ...string) string
+	Int(key string) int
+	IntDefault(key string, def int) int
+	Bool(key string) bool
+	Double(key string) float64
+}
+
+// obsData is a settingsData for an obs_data_t, which the caller still owns.
+type obsData struct {
+	data *C.obs_data_t
+}
+
+func (d obsData) String(keys ...string) string       { return obsDataString(d.data, keys...) }
+func (d obsData) Int(key string) int                 { return obsDataInt(d.data, key) }
+func (d obsData) IntDefault(key string, def int) int { return obsDataIntDefault(d.data, key, def) }
+func (d obsData) Bool(key string) bool               { return obsDataBool(d.data, key) }
+func (d obsData) Double(key string) float64          { return obsDataDouble(d.data, key) }
+
+func sourceSettingsJSON(s *C.obs_source_t) string {
+	return obsDataJSON(C.obs_source_get_settings(s))
+}
+
+// canonicalJSON re-encodes a JSON document with sorted object keys and no insignificant whitespace.
+func canonicalJSON(s string) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// settingsHash returns a hash of the canonicalized JSON settings document.
+// A 32-bit hash is used so the value is exactly representable as a float64 sample.
+func settingsHash(settingsJSON string) uint32 {
+	b, err := canonicalJSON(settingsJSON)
+	if err != nil {
+		// Hash the raw document; it's still stable for identical input.
+		b = []byte(settingsJSON)
+	}
+	h := fnv.New32a()
+	h.Write(b)
+	return h.Sum32()
+}
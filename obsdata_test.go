@@ -0,0 +1,61 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestSettingsHash(t *testing.T) {
+	a := settingsHash(`{"url": "https://example.com", "width": 1920}`)
+	if b := settingsHash(`{"width":1920,"url":"https://example.com"}`); a != b {
+		t.Errorf("the same settings in a different order hash to %d and %d, want them equal", a, b)
+	}
+	if b := settingsHash(`{"url": "https://example.com", "width": 1280}`); a == b {
+		t.Errorf("changed settings hash to %d, the same as before", b)
+	}
+	if a, b := settingsHash(`not json`), settingsHash(`not json`); a != b {
+		t.Errorf("invalid JSON hashes to %d and %d, want it to be stable", a, b)
+	}
+}
+
+// fakeSettings is a settingsData holding its values in a map.
+type fakeSettings map[string]interface{}
+
+func (f fakeSettings) String(keys ...string) string {
+	for _, key := range keys {
+		if v, ok := f[key].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (f fakeSettings) Int(key string) int { return f.IntDefault(key, 0) }
+
+func (f fakeSettings) IntDefault(key string, def int) int {
+	if v, ok := f[key].(int); ok {
+		return v
+	}
+	return def
+}
+
+func (f fakeSettings) Bool(key string) bool {
+	v, _ := f[key].(bool)
+	return v
+}
+
+func (f fakeSettings) Double(key string) float64 {
+	v, _ := f[key].(float64)
+	return v
+}
@@ -0,0 +1,216 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// This file implements just enough of the OTLP/HTTP JSON encoding to export gauges and counters,
+// so that we don't need to depend on the OpenTelemetry SDK.
+
+const (
+	otlpScopeName = "obs-studio-exporter"
+	// AGGREGATION_TEMPORALITY_CUMULATIVE
+	otlpTemporalityCumulative = 2
+)
+
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Gauge       *otlpGauge `json:"gauge,omitempty"`
+	Sum         *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsDouble          otlpDouble     `json:"asDouble"`
+}
+
+// otlpDouble is a float64 which encodes non-finite values, like the -Inf of a silent audio
+// channel, as the strings the protobuf JSON mapping uses for them.
+type otlpDouble float64
+
+func (v otlpDouble) MarshalJSON() ([]byte, error) {
+	f := float64(v)
+	switch {
+	case math.IsNaN(f):
+		return json.Marshal("NaN")
+	case math.IsInf(f, 1):
+		return json.Marshal("Infinity")
+	case math.IsInf(f, -1):
+		return json.Marshal("-Infinity")
+	}
+	return json.Marshal(f)
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func otlpUnixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpAttributes(labels []*dto.LabelPair) []otlpKeyValue {
+	var attrs []otlpKeyValue
+	for _, l := range labels {
+		attrs = append(attrs, otlpKeyValue{Key: l.GetName(), Value: otlpAnyValue{StringValue: l.GetValue()}})
+	}
+	return attrs
+}
+
+// otlpFromFamilies converts gathered Prometheus metric families into an OTLP export request.
+// Gauges and untyped metrics become OTLP gauges, and counters become cumulative monotonic sums.
+// Other metric types are skipped.
+func otlpFromFamilies(mfs []*dto.MetricFamily, start, now time.Time) *otlpRequest {
+	var metrics []otlpMetric
+	for _, mf := range mfs {
+		m := otlpMetric{Name: mf.GetName(), Description: mf.GetHelp()}
+		var points []otlpNumberDataPoint
+		for _, pm := range mf.GetMetric() {
+			p := otlpNumberDataPoint{
+				Attributes:   otlpAttributes(pm.GetLabel()),
+				TimeUnixNano: otlpUnixNano(now),
+			}
+			if pm.TimestampMs != nil {
+				p.TimeUnixNano = otlpUnixNano(time.UnixMilli(pm.GetTimestampMs()))
+			}
+			switch mf.GetType() {
+			case dto.MetricType_GAUGE:
+				p.AsDouble = otlpDouble(pm.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				p.AsDouble = otlpDouble(pm.GetUntyped().GetValue())
+			case dto.MetricType_COUNTER:
+				p.AsDouble = otlpDouble(pm.GetCounter().GetValue())
+				p.StartTimeUnixNano = otlpUnixNano(start)
+			default:
+				continue
+			}
+			points = append(points, p)
+		}
+		if len(points) == 0 {
+			continue
+		}
+		if mf.GetType() == dto.MetricType_COUNTER {
+			m.Sum = &otlpSum{DataPoints: points, AggregationTemporality: otlpTemporalityCumulative, IsMonotonic: true}
+		} else {
+			m.Gauge = &otlpGauge{DataPoints: points}
+		}
+		metrics = append(metrics, m)
+	}
+	return &otlpRequest{
+		ResourceMetrics: []otlpResourceMetrics{{
+			Resource: otlpResource{Attributes: []otlpKeyValue{
+				{Key: "service.name", Value: otlpAnyValue{StringValue: "obs-studio"}},
+			}},
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpScope{Name: otlpScopeName},
+				Metrics: metrics,
+			}},
+		}},
+	}
+}
+
+func exportOTLP(ctx context.Context, endpoint string, start time.Time) error {
+	mfs, err := exportedMetrics.Gather()
+	if err != nil {
+		return fmt.Errorf("gathering metrics: %w", err)
+	}
+	body, err := json.Marshal(otlpFromFamilies(mfs, start, time.Now()))
+	if err != nil {
+		return fmt.Errorf("encoding OTLP request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+func runOTLPExporter(ctx context.Context, endpoint string, interval time.Duration) {
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		heartbeat(ctx, interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := exportOTLP(ctx, endpoint, start); err != nil && ctx.Err() == nil {
+			slog.Warn("OTLP export failed", "endpoint", endpoint, "err", err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestOTLPFromFamilies(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	peak := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "obs_source_channel_peak", Help: "Peak."}, []string{"source_name"})
+	frames := prometheus.NewCounter(prometheus.CounterOpts{Name: "obs_global_total_frames", Help: "Frames."})
+	hist := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "obs_skipped", Help: "Skipped."})
+	reg.MustRegister(peak, frames, hist)
+	peak.WithLabelValues("Mic").Set(-6)
+	peak.WithLabelValues("Silent").Set(math.Inf(-1))
+	frames.Add(42)
+	hist.Observe(1)
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	start := time.Unix(100, 0)
+	now := time.Unix(200, 0)
+	req := otlpFromFamilies(mfs, start, now)
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	var got struct {
+		ResourceMetrics []struct {
+			ScopeMetrics []struct {
+				Metrics []struct {
+					Name  string
+					Gauge *struct {
+						DataPoints []struct {
+							Attributes []struct {
+								Key   string
+								Value struct{ StringValue string }
+							}
+							TimeUnixNano string
+							AsDouble     interface{}
+						}
+					}
+					Sum *struct {
+						DataPoints []struct {
+							StartTimeUnixNano string
+							AsDouble          interface{}
+						}
+						AggregationTemporality int
+						IsMonotonic            bool
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("json.Unmarshal(%s): %v", body, err)
+	}
+	metrics := got.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(metrics) != 2 {
+		t.Fatalf("got %d metrics, want 2 (the histogram should be skipped): %s", len(metrics), body)
+	}
+
+	c := metrics[0]
+	if c.Name != "obs_global_total_frames" || c.Sum == nil {
+		t.Fatalf("metrics[0] = %+v, want the counter as a sum", c)
+	}
+	if !c.Sum.IsMonotonic || c.Sum.AggregationTemporality != otlpTemporalityCumulative {
+		t.Errorf("counter sum = %+v, want monotonic and cumulative", c.Sum)
+	}
+	if p := c.Sum.DataPoints[0]; p.AsDouble != 42.0 || p.StartTimeUnixNano != "100000000000" {
+		t.Errorf("counter point = %+v, want 42 starting at 100s", p)
+	}
+
+	g := metrics[1]
+	if g.Name != "obs_source_channel_peak" || g.Gauge == nil || len(g.Gauge.DataPoints) != 2 {
+		t.Fatalf("metrics[1] = %+v, want the gauge with two points", g)
+	}
+	want := map[string]interface{}{"Mic": -6.0, "Silent": "-Infinity"}
+	for _, p := range g.Gauge.DataPoints {
+		name := p.Attributes[0].Value.StringValue
+		if p.AsDouble != want[name] {
+			t.Errorf("gauge point for %q = %v, want %v", name, p.AsDouble, want[name])
+		}
+		if p.TimeUnixNano != "200000000000" {
+			t.Errorf("gauge point for %q at %s, want 200s", name, p.TimeUnixNano)
+		}
+	}
+}
+
+func TestOTLPDoubleMarshalJSON(t *testing.T) {
+	for _, tc := range []struct {
+		v    float64
+		want string
+	}{
+		{1.5, `1.5`},
+		{0, `0`},
+		{math.Inf(1), `"Infinity"`},
+		{math.Inf(-1), `"-Infinity"`},
+		{math.NaN(), `"NaN"`},
+	} {
+		b, err := json.Marshal(otlpDouble(tc.v))
+		if err != nil {
+			t.Errorf("json.Marshal(%v): %v", tc.v, err)
+			continue
+		}
+		if string(b) != tc.want {
+			t.Errorf("json.Marshal(%v) = %s, want %s", tc.v, b, tc.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <obs.h>
+*/
+import "C"
+
+import (
+	"time"
+)
+
+// libobs only counts bytes per output, so we split them between the output's
+// encoders in proportion to the bitrates they're configured with.
+
+// outputEncoderBitrates returns the configured bitrates, in kbps, of the video encoder
+// and the sum of the audio encoders linked to an output.
+func outputEncoderBitrates(o *C.obs_output_t) (video, audio float64) {
+	if e := C.obs_output_get_video_encoder(o); e != nil {
+		video = float64(getEncoderSettings(e).Bitrate)
+	}
+	for idx := 0; idx < C.MAX_OUTPUT_AUDIO_ENCODERS; idx++ {
+		if e := C.obs_output_get_audio_encoder(o, C.size_t(idx)); e != nil {
+			audio += float64(getEncoderSettings(e).Bitrate)
+		}
+	}
+	return video, audio
+}
+
+// outputDynamicBitrate reports whether the frontend enabled dynamic bitrate on an output.
+// When it's on, the RTMP output lowers and raises its video encoder's bitrate setting as
+// the network allows, so that's the bitrate currently being encoded at. kbps is only set
+// if the output has a video encoder with a bitrate setting.
+func outputDynamicBitrate(o *C.obs_output_t) (enabled bool, kbps float64, ok bool) {
+	// libobs treats NULL data as empty, so an output without settings has dynamic bitrate off.
+	settings := C.obs_output_get_settings(o)
+	if settings != nil {
+		defer C.obs_data_release(settings)
+	}
+	encoder := encoderSettings{GPU: -1}
+	if e := C.obs_output_get_video_encoder(o); e != nil {
+		encoder = getEncoderSettings(e)
+	}
+	return dynamicBitrate(obsData{settings}, encoder)
+}
+
+// dynamicBitrate works out outputDynamicBitrate from the output's settings and its video encoder's.
+func dynamicBitrate(output settingsData, encoder encoderSettings) (enabled bool, kbps float64, ok bool) {
+	enabled = output.Bool("dyn_bitrate")
+	if encoder.Bitrate > 0 {
+		return enabled, float64(encoder.Bitrate), true
+	}
+	return enabled, 0, false
+}
+
+// outputHasEncoders reports whether an output has a video encoder and at least one audio encoder attached.
+func outputHasEncoders(o *C.obs_output_t) (video, audio bool) {
+	video = C.obs_output_get_video_encoder(o) != nil
+	for idx := 0; idx < C.MAX_OUTPUT_AUDIO_ENCODERS; idx++ {
+		if C.obs_output_get_audio_encoder(o, C.size_t(idx)) != nil {
+			audio = true
+			break
+		}
+	}
+	return video, audio
+}
+
+// splitBytes attributes a byte delta between video and audio by weight.
+// It returns false if neither has any weight, so there's nothing to split by.
+func splitBytes(delta uint64, videoWeight, audioWeight float64) (video, audio float64, ok bool) {
+	total := videoWeight + audioWeight
+	if total <= 0 {
+		return 0, 0, false
+	}
+	video = float64(delta) * videoWeight / total
+	return video, float64(delta) - video, true
+}
+
+// updateBitrate records the output's byte count and returns the video and audio bitrates
+// in kbps since the last update, split by the given weights. It returns false if there's
+// no previous sample to compare against, or the byte count went backwards because the
+// output was restarted.
+func (s *outputState) updateBitrate(bytes uint64, now time.Time, videoWeight, audioWeight float64) (videoKbps, audioKbps float64, ok bool) {
+	lastBytes, lastSample := s.LastBytes, s.LastSample
+	s.LastBytes, s.LastSample = bytes, now
+	if lastSample.IsZero() || bytes < lastBytes {
+		return 0, 0, false
+	}
+	elapsed := now.Sub(lastSample).Seconds()
+	if elapsed <= 0 {
+		return 0, 0, false
+	}
+	video, audio, ok := splitBytes(bytes-lastBytes, videoWeight, audioWeight)
+	if !ok {
+		return 0, 0, false
+	}
+	return video * 8 / 1000 / elapsed, audio * 8 / 1000 / elapsed, true
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitBytes(t *testing.T) {
+	for _, tc := range []struct {
+		delta                    uint64
+		videoWeight, audioWeight float64
+		wantVideo, wantAudio     float64
+		wantOK                   bool
+	}{
+		{6160, 6000, 160, 6000, 160, true},
+		{1000, 1000, 0, 1000, 0, true},
+		{1000, 0, 320, 0, 1000, true},
+		{1000, 0, 0, 0, 0, false},
+	} {
+		video, audio, ok := splitBytes(tc.delta, tc.videoWeight, tc.audioWeight)
+		if video != tc.wantVideo || audio != tc.wantAudio || ok != tc.wantOK {
+			t.Errorf("splitBytes(%d, %v, %v) = %v, %v, %v, want %v, %v, %v",
+				tc.delta, tc.videoWeight, tc.audioWeight, video, audio, ok, tc.wantVideo, tc.wantAudio, tc.wantOK)
+		}
+	}
+}
+
+func TestUpdateBitrate(t *testing.T) {
+	var s outputState
+	start := time.Now()
+	if _, _, ok := s.updateBitrate(0, start, 6000, 160); ok {
+		t.Error("updateBitrate returned a bitrate without a previous sample")
+	}
+	// 770000 bytes in one second is 6160 kbps, split 6000 to 160 between the encoders.
+	video, audio, ok := s.updateBitrate(770000, start.Add(time.Second), 6000, 160)
+	if !ok || video != 6000 || audio != 160 {
+		t.Errorf("updateBitrate = %v, %v, %v, want 6000, 160, true", video, audio, ok)
+	}
+	if _, _, ok := s.updateBitrate(1000, start.Add(2*time.Second), 6000, 160); ok {
+		t.Error("updateBitrate returned a bitrate after the byte count went backwards")
+	}
+}
+
+func TestEmitOutputMissingEncoder(t *testing.T) {
+	c := newTestCollector(t)
+	snap := &collectorSnapshot{Up: true, Outputs: []outputSnapshot{
+		{ID: "ffmpeg_output", Name: "custom", EncodesVideo: true, EncodesAudio: true, HasVideoEncoder: true},
+		// Outputs that don't encode anything, like the virtual camera, don't have encoders to check.
+		{ID: "virtualcam_output", Name: "virtualcam_output"},
+	}}
+	ms := emitSnapshot(t, c, snap)
+
+	for _, tc := range []struct {
+		name string
+		want float64
+	}{
+		{"obs_output_has_video_encoder", 1},
+		{"obs_output_has_audio_encoder", 0},
+	} {
+		m, ok := findMetric(ms, tc.name, map[string]string{"output_name": "custom"})
+		if !ok {
+			t.Errorf("no %s for an output with a missing encoder", tc.name)
+		} else if m.Value != tc.want {
+			t.Errorf("%s = %v, want %v", tc.name, m.Value, tc.want)
+		}
+		if _, ok := findMetric(ms, tc.name, map[string]string{"output_name": "virtualcam_output"}); ok {
+			t.Errorf("%s emitted for an output that doesn't encode", tc.name)
+		}
+	}
+}
+
+func TestDynamicBitrate(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		output, encoder fakeSettings
+		enabled         bool
+		kbps            float64
+		ok              bool
+	}{
+		{"enabled", fakeSettings{"dyn_bitrate": true}, fakeSettings{"bitrate": 4500}, true, 4500, true},
+		{"disabled", fakeSettings{}, fakeSettings{"bitrate": 6000}, false, 6000, true},
+		// Lossless and CQP encoders don't have a bitrate to report.
+		{"no bitrate", fakeSettings{"dyn_bitrate": true}, fakeSettings{"rate_control": "CQP"}, true, 0, false},
+	} {
+		enabled, kbps, ok := dynamicBitrate(tc.output, encoderSettingsFromData(tc.encoder))
+		if enabled != tc.enabled || kbps != tc.kbps || ok != tc.ok {
+			t.Errorf("%s: dynamicBitrate = %v, %v, %v; want %v, %v, %v", tc.name, enabled, kbps, ok, tc.enabled, tc.kbps, tc.ok)
+		}
+	}
+}
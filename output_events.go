@@ -0,0 +1,173 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <stdlib.h>
+#include <obs.h>
+
+void mc_output_start_cb(void*, calldata_t*);
+void mc_output_stop_event_cb(void*, calldata_t*);
+void mc_output_reconnect_cb(void*, calldata_t*);
+void mc_output_reconnect_success_cb(void*, calldata_t*);
+*/
+import "C"
+
+import (
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// outputEventSignals are the output signals we count, in the order of the event argument
+// the trampolines pass to mc_output_event_cb_go.
+var outputEventSignals = []struct {
+	Name     string
+	Signal   *C.char
+	Callback C.signal_callback_t
+}{
+	{"start", C.CString("start"), C.signal_callback_t(C.mc_output_start_cb)},
+	{"stop", C.CString("stop"), C.signal_callback_t(C.mc_output_stop_event_cb)},
+	{"reconnect", C.CString("reconnect"), C.signal_callback_t(C.mc_output_reconnect_cb)},
+	{"reconnect_success", C.CString("reconnect_success"), C.signal_callback_t(C.mc_output_reconnect_success_cb)},
+}
+
+var outputEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: outputSubsystem,
+	Name:      "events_total",
+	Help:      "Number of times each output has emitted a start, stop, reconnect or reconnect_success signal.",
+}, []string{"output_id", "output_name", "event"})
+
+// Signals are raised on OBS's output threads, possibly while a scrape holds obsLock,
+// so the callback keeps what it needs here rather than in the collector's state.
+var (
+	outputEventsMu sync.Mutex
+	outputEventIDs = map[string]string{}
+	// outputReconnectAt is when each output's next reconnect attempt is due, from its last reconnect signal.
+	outputReconnectAt = map[string]time.Time{}
+)
+
+// reconnectDelayRemaining returns how long until an output's next reconnect attempt, or false if we don't know.
+func reconnectDelayRemaining(name string, now time.Time) (time.Duration, bool) {
+	outputEventsMu.Lock()
+	at, ok := outputReconnectAt[name]
+	outputEventsMu.Unlock()
+	if !ok {
+		return 0, false
+	}
+	if remaining := at.Sub(now); remaining > 0 {
+		return remaining, true
+	}
+	return 0, true
+}
+
+// outputReconnectDelay returns the seconds until a reconnecting output's next attempt.
+// A stale delay from an earlier reconnect isn't reported once the output stops reconnecting.
+func outputReconnectDelay(name string, reconnecting bool, now time.Time) (float64, bool) {
+	if !reconnecting {
+		return 0, false
+	}
+	remaining, ok := reconnectDelayRemaining(name, now)
+	return remaining.Seconds(), ok
+}
+
+// outputSignals is an output's connected event signal handlers.
+// We only keep a weak reference, so that we don't keep the output alive.
+type outputSignals struct {
+	CName *C.char
+	Weak  *C.obs_weak_output_t
+}
+
+// connectEvents hooks up the event signal handlers for a newly tracked output.
+func (s *outputState) connectEvents(o *C.obs_output_t, name string) {
+	trackOutputEvents(s.ID, name)
+	s.Signals = &outputSignals{
+		CName: C.CString(name),
+		Weak:  C.obs_output_get_weak_output(o),
+	}
+	sh := C.obs_output_get_signal_handler(o)
+	for _, e := range outputEventSignals {
+		C.signal_handler_connect(sh, e.Signal, e.Callback, unsafe.Pointer(s.Signals.CName))
+	}
+}
+
+// disconnectEvents undoes connectEvents. If the output has already been destroyed, so have its signal handlers.
+func (s *outputState) disconnectEvents(name string) {
+	if s.Signals == nil {
+		return
+	}
+	if o := C.obs_weak_output_get_output(s.Signals.Weak); o != nil {
+		sh := C.obs_output_get_signal_handler(o)
+		for _, e := range outputEventSignals {
+			C.signal_handler_disconnect(sh, e.Signal, e.Callback, unsafe.Pointer(s.Signals.CName))
+		}
+		C.obs_output_release(o)
+	}
+	C.obs_weak_output_release(s.Signals.Weak)
+	C.free(unsafe.Pointer(s.Signals.CName))
+	s.Signals = nil
+	untrackOutputEvents(s.ID, name)
+}
+
+// trackOutputEvents starts counting the events of an output, before its signal handlers are connected.
+func trackOutputEvents(id, name string) {
+	outputEventsMu.Lock()
+	defer outputEventsMu.Unlock()
+	outputEventIDs[name] = id
+}
+
+// untrackOutputEvents forgets an output whose signal handlers have been disconnected.
+func untrackOutputEvents(id, name string) {
+	outputEventsMu.Lock()
+	delete(outputEventIDs, name)
+	delete(outputReconnectAt, name)
+	outputEventsMu.Unlock()
+	for _, e := range outputEventSignals {
+		outputEvents.DeleteLabelValues(id, name, e.Name)
+	}
+}
+
+// recordOutputEvent counts an event signal from the output called name, given its index in outputEventSignals.
+func recordOutputEvent(name string, event int, reconnectTimeout time.Duration, now time.Time) {
+	if event < 0 || event >= len(outputEventSignals) {
+		return
+	}
+	kind := outputEventSignals[event].Name
+
+	outputEventsMu.Lock()
+	id, ok := outputEventIDs[name]
+	if ok {
+		switch kind {
+		case "reconnect":
+			outputReconnectAt[name] = now.Add(reconnectTimeout)
+		case "reconnect_success", "stop":
+			delete(outputReconnectAt, name)
+		}
+	}
+	outputEventsMu.Unlock()
+	if !ok {
+		return
+	}
+	outputEvents.WithLabelValues(id, name, kind).Inc()
+}
+
+//export mc_output_event_cb_go
+func mc_output_event_cb_go(f unsafe.Pointer, event C.int, reconnectTimeoutSec C.longlong) {
+	recordOutputEvent(C.GoString((*C.char)(f)), int(event), time.Duration(reconnectTimeoutSec)*time.Second, time.Now())
+}
@@ -0,0 +1,116 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// outputEventIndex returns the index of the named event in outputEventSignals.
+func outputEventIndex(t *testing.T, name string) int {
+	t.Helper()
+	for n, e := range outputEventSignals {
+		if e.Name == name {
+			return n
+		}
+	}
+	t.Fatalf("no output event %q", name)
+	return -1
+}
+
+func TestOutputEventsLifecycle(t *testing.T) {
+	const id, name = "rtmp_output", "test_stream"
+	count := func(event string) float64 {
+		return testutil.ToFloat64(outputEvents.WithLabelValues(id, name, event))
+	}
+	now := time.Now()
+
+	// Signals from outputs we aren't tracking, or that we don't know, are ignored.
+	recordOutputEvent(name, outputEventIndex(t, "start"), 0, now)
+	recordOutputEvent(name, len(outputEventSignals), 0, now)
+	if n := testutil.CollectAndCount(outputEvents); n != 0 {
+		t.Errorf("%d series counted for an output that isn't tracked", n)
+	}
+
+	trackOutputEvents(id, name)
+	recordOutputEvent(name, outputEventIndex(t, "start"), 0, now)
+	recordOutputEvent(name, outputEventIndex(t, "reconnect"), 10*time.Second, now)
+	if remaining, ok := reconnectDelayRemaining(name, now.Add(4*time.Second)); !ok || remaining != 6*time.Second {
+		t.Errorf("reconnect delay remaining = %v, %v, want 6s, true", remaining, ok)
+	}
+	recordOutputEvent(name, outputEventIndex(t, "reconnect"), 10*time.Second, now)
+	recordOutputEvent(name, outputEventIndex(t, "reconnect_success"), 0, now)
+	if _, ok := reconnectDelayRemaining(name, now); ok {
+		t.Error("reconnect delay still known after reconnecting")
+	}
+	for event, want := range map[string]float64{"start": 1, "reconnect": 2, "reconnect_success": 1, "stop": 0} {
+		if got := count(event); got != want {
+			t.Errorf("%s events = %v, want %v", event, got, want)
+		}
+	}
+
+	untrackOutputEvents(id, name)
+	if n := testutil.CollectAndCount(outputEvents); n != 0 {
+		t.Errorf("%d series left after the output's signal handlers were disconnected", n)
+	}
+	recordOutputEvent(name, outputEventIndex(t, "stop"), 0, now)
+	if n := testutil.CollectAndCount(outputEvents); n != 0 {
+		t.Error("events counted after the output's signal handlers were disconnected")
+	}
+}
+
+func TestOutputReconnectDelay(t *testing.T) {
+	const id, name = "rtmp_output", "test_reconnect_delay"
+	trackOutputEvents(id, name)
+	defer untrackOutputEvents(id, name)
+	now := time.Now()
+
+	if _, ok := outputReconnectDelay(name, true, now); ok {
+		t.Error("reconnect delay reported before any reconnect signal")
+	}
+	recordOutputEvent(name, outputEventIndex(t, "reconnect"), 10*time.Second, now)
+	if remaining, ok := outputReconnectDelay(name, true, now.Add(3*time.Second)); !ok || remaining != 7 {
+		t.Errorf("reconnect delay = %v, %v, want 7, true", remaining, ok)
+	}
+	if remaining, ok := outputReconnectDelay(name, true, now.Add(time.Minute)); !ok || remaining != 0 {
+		t.Errorf("overdue reconnect delay = %v, %v, want 0, true", remaining, ok)
+	}
+	// The signal handler may not have cleared the delay yet, but an output that isn't reconnecting has none.
+	if _, ok := outputReconnectDelay(name, false, now); ok {
+		t.Error("reconnect delay reported for an output that isn't reconnecting")
+	}
+}
+
+func TestEmitReconnectDelayOnlyWhenKnown(t *testing.T) {
+	c := newTestCollector(t)
+	const metric = "obs_output_reconnect_delay_seconds_remaining"
+	snap := &collectorSnapshot{Up: true, Outputs: []outputSnapshot{
+		{ID: "rtmp_output", Name: "simple_stream", Reconnecting: true, HasReconnectDelay: true, ReconnectDelayRemaining: 4},
+		{ID: "rtmp_output", Name: "backup_stream", Reconnecting: true},
+		{ID: "ffmpeg_muxer", Name: "simple_file_output", Active: true},
+	}}
+	ms := emitSnapshot(t, c, snap)
+	if m, ok := findMetric(ms, metric, map[string]string{"output_name": "simple_stream"}); !ok || m.Value != 4 {
+		t.Errorf("%s for simple_stream = %v, %v, want 4", metric, m.Value, ok)
+	}
+	for _, name := range []string{"backup_stream", "simple_file_output"} {
+		if _, ok := findMetric(ms, metric, map[string]string{"output_name": name}); ok {
+			t.Errorf("%s emitted for %s without a known delay", metric, name)
+		}
+	}
+}
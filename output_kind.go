@@ -0,0 +1,49 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+const (
+	outputKindStreaming    = "streaming"
+	outputKindRecording    = "recording"
+	outputKindVirtualCam   = "virtualcam"
+	outputKindReplayBuffer = "replay_buffer"
+	outputKindOther        = "other"
+)
+
+// outputKinds maps the IDs of the outputs that ship with OBS to what they're used for.
+var outputKinds = map[string]string{
+	"rtmp_output":         outputKindStreaming,
+	"ftl_output":          outputKindStreaming,
+	"whip_output":         outputKindStreaming,
+	"ffmpeg_mpegts_muxer": outputKindStreaming,
+	"ffmpeg_muxer":        outputKindRecording,
+	"ffmpeg_output":       outputKindRecording,
+	"mp4_output":          outputKindRecording,
+	"flv_output":          outputKindRecording,
+	"replay_buffer":       outputKindReplayBuffer,
+	"virtualcam_output":   outputKindVirtualCam,
+	"v4l2_output":         outputKindVirtualCam,
+}
+
+// outputKind classifies an output. Anything unknown that connects to a streaming service is assumed to be streaming.
+func outputKind(id string, usesService bool) string {
+	if kind, ok := outputKinds[id]; ok {
+		return kind
+	}
+	if usesService {
+		return outputKindStreaming
+	}
+	return outputKindOther
+}
@@ -0,0 +1,40 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestOutputKind(t *testing.T) {
+	for _, tc := range []struct {
+		id          string
+		usesService bool
+		want        string
+	}{
+		{"rtmp_output", true, outputKindStreaming},
+		{"whip_output", true, outputKindStreaming},
+		{"ffmpeg_muxer", false, outputKindRecording},
+		{"mp4_output", false, outputKindRecording},
+		{"replay_buffer", false, outputKindReplayBuffer},
+		{"virtualcam_output", false, outputKindVirtualCam},
+		{"v4l2_output", false, outputKindVirtualCam},
+		// Outputs from other plugins are classified by whether they use a streaming service.
+		{"obs_ndi_output", false, outputKindOther},
+		{"some_plugin_stream_output", true, outputKindStreaming},
+	} {
+		if got := outputKind(tc.id, tc.usesService); got != tc.want {
+			t.Errorf("outputKind(%q, %v) = %q, want %q", tc.id, tc.usesService, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <obs.h>
+#include <util/platform.h>
+#ifndef _WIN32
+#include <dlfcn.h>
+#endif
+
+// obs_service_get_connect_info replaced obs_service_get_url in libobs 29.1, so it's looked up
+// when the module loads rather than linked against, and the old function is used without it.
+static void *mc_libobs;
+static __typeof__(obs_service_get_connect_info) *mc_service_get_connect_info;
+
+static void mc_service_load(void) {
+#ifdef _WIN32
+	mc_libobs = os_dlopen("obs");
+#else
+	mc_libobs = dlopen(NULL, RTLD_LAZY);
+#endif
+	if (mc_libobs)
+		mc_service_get_connect_info = os_dlsym(mc_libobs, "obs_service_get_connect_info");
+}
+
+static void mc_service_unload(void) {
+	if (mc_libobs)
+		os_dlclose(mc_libobs);
+	mc_libobs = NULL;
+	mc_service_get_connect_info = NULL;
+}
+
+static const char *mc_service_server_url(const obs_service_t *service) {
+	if (mc_service_get_connect_info)
+		return mc_service_get_connect_info(service, OBS_SERVICE_CONNECT_INFO_SERVER_URL);
+	return obs_service_get_url(service);
+}
+*/
+import "C"
+
+import (
+	"net/url"
+	"strings"
+)
+
+// serverHost returns just the host of a streaming server URL, such as
+// rtmp://live.example.com/app or srt://ingest.example.com:9000?streamid=...
+// Everything else is dropped, since the path or query often contains the stream key.
+func serverHost(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false
+	}
+	if !strings.Contains(raw, "://") {
+		// Some services are configured with a bare host.
+		raw = "//" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	host := u.Hostname()
+	return host, host != ""
+}
+
+func loadServiceAPI() {
+	C.mc_service_load()
+}
+
+func unloadServiceAPI() {
+	C.mc_service_unload()
+}
+
+// outputServerHost returns the host of the server a streaming output is sending to.
+func outputServerHost(o *C.obs_output_t) (string, bool) {
+	service := C.obs_output_get_service(o)
+	if service == nil {
+		return "", false
+	}
+	return serverHost(C.GoString(C.mc_service_server_url(service)))
+}
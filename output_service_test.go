@@ -0,0 +1,41 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestServerHost(t *testing.T) {
+	for _, tc := range []struct {
+		url    string
+		want   string
+		wantOK bool
+	}{
+		{"rtmp://live.twitch.tv/app", "live.twitch.tv", true},
+		{"rtmps://a.rtmps.youtube.com:443/live2/abcd-efgh", "a.rtmps.youtube.com", true},
+		{"srt://ingest.example.com:9000?streamid=secret&passphrase=hunter2", "ingest.example.com", true},
+		{"rist://[2001:db8::1]:5000", "2001:db8::1", true},
+		{"  rtmp://live.example.com/app  ", "live.example.com", true},
+		{"live.example.com", "live.example.com", true},
+		{"", "", false},
+		{"rtmp://", "", false},
+		{"rtmp://bad host/app", "", false},
+		{"rtmp://%zz/app", "", false},
+	} {
+		got, ok := serverHost(tc.url)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("serverHost(%q) = %q, %v, want %q, %v", tc.url, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var outputConnectTimes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: outputSubsystem,
+	Name:      "connect_time_histogram_seconds",
+	Help:      "Time taken to connect in seconds, observed each time this output becomes active.",
+	Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+}, []string{"output_id", "output_name"})
+
+// droppedFramesRatio returns the fraction of an output's frames that were dropped, like OBS's
+// stats dock does. It returns false if the output hasn't had any frames yet.
+func droppedFramesRatio(dropped, total float64) (float64, bool) {
+	if total <= 0 {
+		return 0, false
+	}
+	return dropped / total, true
+}
+
+// encoderDrops adds up the frames dropped by the outputs each encoder feeds, keyed by the encoder.
+type encoderDrops map[unsafe.Pointer]float64
+
+// attribute counts an output's dropped frames against the encoder feeding it, if it has one.
+func (d encoderDrops) attribute(encoder unsafe.Pointer, dropped float64) {
+	if encoder == nil {
+		return
+	}
+	d[encoder] += dropped
+}
+
+// dropped returns the frames dropped by the outputs an encoder feeds, or false if it doesn't feed any.
+func (d encoderDrops) dropped(encoder unsafe.Pointer) (float64, bool) {
+	dropped, ok := d[encoder]
+	return dropped, ok
+}
+
+// outputState is what we remember about an output between scrapes.
+type outputState struct {
+	ID string
+
+	// Signals is set while the output's event signals are connected.
+	Signals *outputSignals
+
+	// Seen is set once the output has been updated at least once.
+	Seen            bool
+	Active          bool
+	DroppedBaseline int
+	// SessionDropped is the number of frames dropped since the output last became active.
+	SessionDropped int
+
+	// LastBytes and LastSample are the byte count of the output at the last scrape, for working out its bitrate.
+	LastBytes  uint64
+	LastSample time.Time
+
+	// LastDropped and LastCongestion are from the last scrape, for attributing dropped frames to congestion.
+	HasLast        bool
+	LastDropped    int
+	LastCongestion float64
+	// NetworkDropped is the number of frames dropped while the output was congested.
+	NetworkDropped int
+}
+
+// congestionThreshold is the congestion, from 0 to 1, at or above which we consider an output congested.
+const congestionThreshold = 0.1
+
+// updateNetworkDrops attributes frames dropped since the last scrape to the network if the output
+// was congested at either end of that window. It returns the total attributed so far.
+func (s *outputState) updateNetworkDrops(dropped int, congestion float64) int {
+	if s.HasLast {
+		delta := dropped - s.LastDropped
+		if dropped < s.LastDropped {
+			// The output's own counters were reset underneath us.
+			delta = dropped
+		}
+		if delta > 0 && (congestion >= congestionThreshold || s.LastCongestion >= congestionThreshold) {
+			s.NetworkDropped += delta
+		}
+	}
+	s.HasLast, s.LastDropped, s.LastCongestion = true, dropped, congestion
+	return s.NetworkDropped
+}
+
+// update records the current state of the output. It returns true if the output has become active since the last update.
+func (s *outputState) update(active bool, dropped int) bool {
+	activated := active && !s.Active
+	// OBS resets the output's counters when it starts, so an output that was already active when we
+	// first saw it dropped all of its frames this session.
+	if activated && s.Seen {
+		s.DroppedBaseline = dropped
+	}
+	s.Seen = true
+	s.Active = active
+	if !active {
+		s.DroppedBaseline = dropped
+		s.SessionDropped = 0
+		return false
+	}
+	if dropped < s.DroppedBaseline {
+		// The output's own counters were reset underneath us.
+		s.DroppedBaseline = 0
+	}
+	s.SessionDropped = dropped - s.DroppedBaseline
+	return activated
+}
+
+// observe updates the output's state from a scrape, and records how long it took to connect if it has just become active.
+func (s *outputState) observe(name string, active bool, dropped int, connectTime float64) {
+	if s.update(active, dropped) && connectTime > 0 {
+		outputConnectTimes.WithLabelValues(s.ID, name).Observe(connectTime)
+	}
+}
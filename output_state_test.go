@@ -0,0 +1,214 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestOutputSessionDrops(t *testing.T) {
+	var s outputState
+	for _, step := range []struct {
+		active    bool
+		dropped   int
+		activated bool
+		want      int
+	}{
+		{false, 0, false, 0},
+		{true, 3, true, 0},
+		{true, 10, false, 7},
+		// OBS keeps the last session's count until the output starts again.
+		{false, 10, false, 0},
+		{false, 10, false, 0},
+		{true, 12, true, 0},
+		{true, 15, false, 3},
+		// The output's counters going backwards means they were reset.
+		{true, 4, false, 4},
+	} {
+		if got := s.update(step.active, step.dropped); got != step.activated {
+			t.Errorf("update(%v, %d) = %v, want %v", step.active, step.dropped, got, step.activated)
+		}
+		if s.SessionDropped != step.want {
+			t.Errorf("after update(%v, %d), SessionDropped = %d, want %d", step.active, step.dropped, s.SessionDropped, step.want)
+		}
+	}
+}
+
+func TestOutputSessionDropsFirstSeenActive(t *testing.T) {
+	var s outputState
+	s.update(true, 7)
+	if s.SessionDropped != 7 {
+		t.Errorf("SessionDropped = %d for an output first seen active with 7 drops, want 7", s.SessionDropped)
+	}
+}
+
+func TestOutputConnectTimeHistogram(t *testing.T) {
+	s := &outputState{ID: "rtmp_output"}
+	defer outputConnectTimes.DeleteLabelValues(s.ID, "test_stream")
+	for _, step := range []struct {
+		active      bool
+		connectTime float64
+	}{
+		{true, 0.5},
+		// Only the scrape where the output becomes active counts.
+		{true, 0.5},
+		{false, 0},
+		{true, 2},
+		{false, 0},
+		// An output that connected instantly, like a recording, isn't observed.
+		{true, 0},
+	} {
+		s.observe("test_stream", step.active, 0, step.connectTime)
+	}
+
+	var m dto.Metric
+	if err := outputConnectTimes.WithLabelValues(s.ID, "test_stream").(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetHistogram().GetSampleCount(); got != 2 {
+		t.Errorf("observed %d connect times, want 2", got)
+	}
+	if got := m.GetHistogram().GetSampleSum(); got != 2.5 {
+		t.Errorf("connect times add up to %v, want 2.5", got)
+	}
+}
+
+func TestOutputNetworkDrops(t *testing.T) {
+	var s outputState
+	for _, step := range []struct {
+		dropped    int
+		congestion float64
+		want       int
+	}{
+		// Nothing is attributed on the first scrape, as there's no window to attribute it to.
+		{5, 0.5, 0},
+		// Congestion at either end of the window counts.
+		{8, 0, 3},
+		// Drops while uncongested are encoder or render lag, not the network.
+		{10, 0, 3},
+		{12, 0.2, 3 + 2},
+		{12, 0.9, 5},
+		// The output's counters going backwards means they were reset.
+		{4, 0.5, 4 + 5},
+	} {
+		if got := s.updateNetworkDrops(step.dropped, step.congestion); got != step.want {
+			t.Errorf("updateNetworkDrops(%d, %v) = %d, want %d", step.dropped, step.congestion, got, step.want)
+		}
+	}
+}
+
+func TestEmitOutputDropsTotal(t *testing.T) {
+	c := newTestCollector(t)
+	outputs := []outputSnapshot{
+		{ID: "rtmp_output", Name: "simple_stream", DroppedFrames: 12, TotalFrames: 6000},
+		{ID: "ffmpeg_muxer", Name: "simple_file_output", DroppedFrames: 3, TotalFrames: 300},
+		// Outputs that haven't sent anything yet don't have a ratio.
+		{ID: "replay_buffer", Name: "Replay Buffer"},
+	}
+	ch := make(chan prometheus.Metric, 16)
+	c.emitOutputDrops(metricSink{ch, currentConfig()}, outputs)
+	close(ch)
+
+	perOutput := map[string]float64{}
+	ratios := map[string]float64{}
+	var total float64
+	var totals int
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatal(err)
+		}
+		var output string
+		for _, l := range pb.GetLabel() {
+			if l.GetName() == "output_name" {
+				output = l.GetValue()
+			}
+		}
+		switch m.Desc() {
+		case c.DroppedFramesPerOutput:
+			perOutput[output] = pb.GetCounter().GetValue()
+		case c.DroppedFramesRatioPerOutput:
+			ratios[output] = pb.GetGauge().GetValue()
+		case c.TotalDroppedFrames:
+			total = pb.GetGauge().GetValue()
+			totals++
+		}
+	}
+
+	var sum float64
+	for _, dropped := range perOutput {
+		sum += dropped
+	}
+	if len(perOutput) != len(outputs) {
+		t.Errorf("dropped frames emitted for %d outputs, want %d", len(perOutput), len(outputs))
+	}
+	if totals != 1 || total != sum || total != 15 {
+		t.Errorf("total dropped frames = %v (emitted %d times), want the per-output sum %v", total, totals, sum)
+	}
+	if want := map[string]float64{"simple_stream": 0.002, "simple_file_output": 0.01}; !reflect.DeepEqual(ratios, want) {
+		t.Errorf("dropped frames ratios = %v, want %v", ratios, want)
+	}
+}
+
+func TestEncoderDropsAttribution(t *testing.T) {
+	// Stand-ins for encoder pointers; only their identity matters.
+	var streamEncoder, recordEncoder, idleEncoder int
+	stream, record, idle := unsafe.Pointer(&streamEncoder), unsafe.Pointer(&recordEncoder), unsafe.Pointer(&idleEncoder)
+
+	d := encoderDrops{}
+	for _, o := range []struct {
+		encoder unsafe.Pointer
+		dropped float64
+	}{
+		// The stream and its backup share an encoder, so both their drops count against it.
+		{stream, 12},
+		{stream, 3},
+		{record, 0},
+		// The virtual camera is a raw output, with no encoder.
+		{nil, 7},
+	} {
+		d.attribute(o.encoder, o.dropped)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		encoder unsafe.Pointer
+		want    float64
+		wantOK  bool
+	}{
+		{"shared encoder", stream, 15, true},
+		{"encoder with no drops", record, 0, true},
+		{"encoder feeding no outputs", idle, 0, false},
+	} {
+		got, ok := d.dropped(tc.encoder)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("%s: dropped = %v, %v, want %v, %v", tc.name, got, ok, tc.want, tc.wantOK)
+		}
+	}
+	if len(d) != 2 {
+		t.Errorf("drops attributed to %d encoders, want 2", len(d))
+	}
+
+	// Before the first output snapshot there's nothing to attribute.
+	var none encoderDrops
+	if _, ok := none.dropped(stream); ok {
+		t.Error("drops found before any outputs were snapshotted")
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <stdlib.h>
+#include <obs.h>
+
+void mc_output_stop_cb(void*, calldata_t*);
+*/
+import "C"
+
+import (
+	"strconv"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The frontend's stopped events don't say why an output stopped, so we listen
+// for the "stop" signal on the frontend's streaming and recording outputs instead.
+
+var (
+	signalStop = C.CString("stop")
+
+	// Passed as the signal data, so the callback knows which output stopped.
+	stopKindStreaming = C.CString("streaming")
+	stopKindRecording = C.CString("recording")
+)
+
+var (
+	lastStreamingStopCode = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: frontendSubsystem,
+		Name:      "last_streaming_stop_code",
+		Help:      "Code the streaming output last stopped with; see obs_frontend_stop_code_info.",
+	})
+
+	lastRecordingStopCode = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: frontendSubsystem,
+		Name:      "last_recording_stop_code",
+		Help:      "Code the recording output last stopped with; see obs_frontend_stop_code_info.",
+	})
+
+	stopCodeInfo = newStopCodeInfo()
+)
+
+// stopReasons describes the codes an output can stop with.
+var stopReasons = map[int]string{
+	C.OBS_OUTPUT_SUCCESS:        "success",
+	C.OBS_OUTPUT_BAD_PATH:       "bad path",
+	C.OBS_OUTPUT_CONNECT_FAILED: "connect failed",
+	C.OBS_OUTPUT_INVALID_STREAM: "invalid stream",
+	C.OBS_OUTPUT_ERROR:          "error",
+	C.OBS_OUTPUT_DISCONNECTED:   "disconnected",
+	C.OBS_OUTPUT_UNSUPPORTED:    "unsupported",
+	C.OBS_OUTPUT_NO_SPACE:       "no space",
+	C.OBS_OUTPUT_ENCODE_ERROR:   "encode error",
+}
+
+// stopReason returns a human-readable description of an output stop code.
+func stopReason(code int) string {
+	if reason, ok := stopReasons[code]; ok {
+		return reason
+	}
+	return "unknown"
+}
+
+func newStopCodeInfo() *prometheus.GaugeVec {
+	v := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: frontendSubsystem,
+		Name:      "stop_code_info",
+		Help:      "Maps the codes outputs stop with to what they mean.",
+	}, []string{"code", "reason"})
+	for code, reason := range stopReasons {
+		v.WithLabelValues(strconv.Itoa(code), reason).Set(1)
+	}
+	return v
+}
+
+// connectOutputStop listens for an output stopping. Connecting the same callback
+// twice is a no-op in libobs, so this is safe to call every time the output starts.
+func connectOutputStop(o *C.obs_output_t, kind *C.char) {
+	if o == nil {
+		return
+	}
+	defer C.obs_output_release(o)
+	sh := C.obs_output_get_signal_handler(o)
+	C.signal_handler_connect(sh, signalStop, C.signal_callback_t(C.mc_output_stop_cb), unsafe.Pointer(kind))
+}
+
+// disconnectOutputStop undoes connectOutputStop.
+func disconnectOutputStop(o *C.obs_output_t, kind *C.char) {
+	if o == nil {
+		return
+	}
+	defer C.obs_output_release(o)
+	sh := C.obs_output_get_signal_handler(o)
+	C.signal_handler_disconnect(sh, signalStop, C.signal_callback_t(C.mc_output_stop_cb), unsafe.Pointer(kind))
+}
+
+func disconnectOutputStopSignals() {
+	disconnectOutputStop(frontendStreamingOutput(), stopKindStreaming)
+	disconnectOutputStop(frontendRecordingOutput(), stopKindRecording)
+}
+
+//export mc_output_stop_cb_go
+func mc_output_stop_cb_go(f unsafe.Pointer, code C.longlong) {
+	switch (*C.char)(f) {
+	case stopKindStreaming:
+		lastStreamingStopCode.Set(float64(code))
+	case stopKindRecording:
+		lastRecordingStopCode.Set(float64(code))
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStopReason(t *testing.T) {
+	for code, want := range map[int]string{
+		0:  "success",
+		-1: "bad path",
+		-2: "connect failed",
+		-5: "disconnected",
+		-7: "no space",
+		-8: "encode error",
+		-9: "unknown",
+		1:  "unknown",
+	} {
+		if got := stopReason(code); got != want {
+			t.Errorf("stopReason(%d) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestStopCodeInfo(t *testing.T) {
+	v := newStopCodeInfo()
+	if got := testutil.ToFloat64(v.WithLabelValues("-7", "no space")); got != 1 {
+		t.Errorf(`stop_code_info{code="-7",reason="no space"} = %v, want 1`, got)
+	}
+	if n := testutil.CollectAndCount(v); n != len(stopReasons) {
+		t.Errorf("stop_code_info has %d series, want one for each of the %d stop codes", n, len(stopReasons))
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultPeakBuckets are the upper bounds, in dBFS, of the peak histogram's buckets.
+var defaultPeakBuckets = []float64{-60, -50, -40, -30, -20, -10, -6, -3, 0}
+
+// peakHistogram is observed with every volmeter update's peak, if enabled in the config.
+// It's only set before any volmeters are created.
+var peakHistogram *prometheus.HistogramVec
+
+func newPeakHistogram(buckets []float64) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: sourceSubsystem,
+		Name:      "peak_histogram_dbfs",
+		Help:      "Peak level of this source in dBFS, observed on every volume meter update.",
+		Buckets:   buckets,
+	}, []string{"source_id", "source_name"})
+}
+
+// parseBuckets parses a comma-separated list of increasing histogram bucket upper bounds.
+func parseBuckets(s string) ([]float64, error) {
+	var buckets []float64
+	for _, field := range strings.Split(s, ",") {
+		b, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, err
+		}
+		if len(buckets) > 0 && b <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("bucket %v isn't greater than the one before it", b)
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, nil
+}
+
+// histogramPeak returns the loudest of a volmeter update's channel peaks. It's no lower than
+// floor, so silence, which is -Inf, lands in the lowest bucket without making the sum infinite.
+func histogramPeak(peaks []float64, floor float64) float64 {
+	peak := floor
+	for _, p := range peaks {
+		peak = math.Max(peak, p)
+	}
+	return peak
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestPeakHistogramBuckets(t *testing.T) {
+	h := newPeakHistogram(defaultPeakBuckets)
+	floor := defaultPeakBuckets[0]
+	for _, peaks := range [][]float64{
+		// Silence lands in the lowest bucket.
+		{math.Inf(-1), math.Inf(-1)},
+		// The loudest channel is what's observed.
+		{-45, -25},
+		{-25},
+		{-4, -70},
+		{-0.5},
+		// Clipping is above every bucket.
+		{2, -10},
+	} {
+		h.WithLabelValues("wasapi_input_capture", "Mic").Observe(histogramPeak(peaks, floor))
+	}
+
+	var m dto.Metric
+	if err := h.WithLabelValues("wasapi_input_capture", "Mic").(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	// Cumulative counts for each of defaultPeakBuckets.
+	want := []uint64{1, 1, 1, 1, 3, 3, 3, 4, 5}
+	var got []uint64
+	for _, b := range m.GetHistogram().GetBucket() {
+		got = append(got, b.GetCumulativeCount())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bucket counts = %v, want %v", got, want)
+	}
+	if n := m.GetHistogram().GetSampleCount(); n != 6 {
+		t.Errorf("sample count = %d, want 6", n)
+	}
+	if sum := m.GetHistogram().GetSampleSum(); math.IsInf(sum, 0) {
+		t.Errorf("sample sum = %v, want silence floored to the lowest bucket", sum)
+	}
+}
+
+func TestParseBuckets(t *testing.T) {
+	got, err := parseBuckets("-40, -20,-6, 0")
+	if err != nil {
+		t.Fatalf("parseBuckets: %v", err)
+	}
+	if want := []float64{-40, -20, -6, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseBuckets = %v, want %v", got, want)
+	}
+	for _, s := range []string{"", "-20,-40", "-20,-20", "-20,loud"} {
+		if _, err := parseBuckets(s); err == nil {
+			t.Errorf("parseBuckets(%q) succeeded, want an error", s)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// The peak hold works like the peak indicator on OBS's volume meters: the highest
+// peak is held for a while, then falls at a steady rate until a louder peak comes along.
+
+// peakHoldDecayDB is the fall the configured decay time is measured over, like OBS's meter decay rates.
+const peakHoldDecayDB = 20
+
+// peakHoldFloorDBFS is where a falling peak hold drops to silence, like the bottom of OBS's meters.
+const peakHoldFloorDBFS = -60
+
+// Ranges accepted for the peak hold settings, in milliseconds.
+const (
+	maxPeakHoldMS  = 10 * 60 * 1000
+	minPeakDecayMS = 100
+	maxPeakDecayMS = 60 * 1000
+)
+
+type peakHold struct {
+	// Level is the peak being held, and HeldAt when it was seen.
+	Level  float64
+	HeldAt time.Time
+}
+
+// value returns the level of the peak hold at now, after holding for hold and then falling
+// by peakHoldDecayDB every decay.
+func (p peakHold) value(now time.Time, hold, decay time.Duration) float64 {
+	falling := now.Sub(p.HeldAt) - hold
+	if falling <= 0 || math.IsInf(p.Level, -1) {
+		return p.Level
+	}
+	level := p.Level - peakHoldDecayDB*falling.Seconds()/decay.Seconds()
+	if level < peakHoldFloorDBFS {
+		return math.Inf(-1)
+	}
+	return level
+}
+
+// update returns the peak hold after seeing peak at now.
+func (p peakHold) update(peak float64, now time.Time, hold, decay time.Duration) peakHold {
+	if peak >= p.value(now, hold, decay) {
+		return peakHold{Level: peak, HeldAt: now}
+	}
+	return p
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPeakHoldDecay(t *testing.T) {
+	start := time.Unix(1000, 0)
+	decay := time.Second
+	for _, tc := range []struct {
+		hold  time.Duration
+		after time.Duration
+		want  float64
+	}{
+		// Still holding.
+		{hold: 2 * time.Second, after: time.Second, want: -10},
+		{hold: 2 * time.Second, after: 2 * time.Second, want: -10},
+		// Falling by 20 dB a second once the hold is over.
+		{hold: 2 * time.Second, after: 2500 * time.Millisecond, want: -20},
+		{hold: 500 * time.Millisecond, after: time.Second, want: -20},
+		{hold: 500 * time.Millisecond, after: 2500 * time.Millisecond, want: -50},
+		// Below the floor, it drops to silence.
+		{hold: 500 * time.Millisecond, after: 3500 * time.Millisecond, want: math.Inf(-1)},
+	} {
+		p := peakHold{Level: math.Inf(-1)}.update(-10, start, tc.hold, decay)
+		if got := p.value(start.Add(tc.after), tc.hold, decay); got != tc.want {
+			t.Errorf("peak hold of -10 dB with hold %v, %v later = %v, want %v", tc.hold, tc.after, got, tc.want)
+		}
+	}
+}
+
+func TestPeakHoldUpdate(t *testing.T) {
+	start := time.Unix(1000, 0)
+	hold, decay := time.Second, time.Second
+	p := peakHold{Level: math.Inf(-1)}
+	for _, s := range []struct {
+		at   time.Duration
+		peak float64
+		want float64
+	}{
+		{0, -20, -20},
+		// Quieter peaks don't replace a held one.
+		{500 * time.Millisecond, -30, -20},
+		// Louder ones do, and are held from then.
+		{600 * time.Millisecond, -10, -10},
+		{1500 * time.Millisecond, -40, -10},
+		// Once the held peak has fallen below a new one, the new one is held.
+		{2100 * time.Millisecond, -15, -15},
+	} {
+		now := start.Add(s.at)
+		p = p.update(s.peak, now, hold, decay)
+		if got := p.value(now, hold, decay); got != s.want {
+			t.Errorf("after a peak of %v at %v, peak hold = %v, want %v", s.peak, s.at, got, s.want)
+		}
+	}
+}
+
+func TestPeakHoldFollowsReloadedConfig(t *testing.T) {
+	defer applyConfig(activeConfig)
+	applyConfig(defaultConfig())
+
+	start := time.Unix(1000, 0)
+	cfg := currentConfig()
+	p := peakHold{Level: math.Inf(-1)}.update(-10, start, cfg.PeakHold, cfg.PeakDecay)
+	now := start.Add(2 * time.Second)
+	if got := p.value(now, cfg.PeakHold, cfg.PeakDecay); got != -10 {
+		t.Fatalf("with the default %v hold, peak hold = %v after 2s, want -10", cfg.PeakHold, got)
+	}
+
+	t.Setenv(envPeakHoldMS, strconv.Itoa(1000))
+	t.Setenv(envPeakDecayMS, strconv.Itoa(1000))
+	reloadConfig()
+
+	cfg = currentConfig()
+	if cfg.PeakHold != time.Second || cfg.PeakDecay != time.Second {
+		t.Fatalf("after reloading, PeakHold = %v and PeakDecay = %v, want 1s and 1s", cfg.PeakHold, cfg.PeakDecay)
+	}
+	if got := p.value(now, cfg.PeakHold, cfg.PeakDecay); got != -30 {
+		t.Errorf("with a reloaded 1s hold and decay, peak hold = %v after 2s, want -30", got)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <util/bmem.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// OBS's defaults for a profile's streaming encoder, used if the profile doesn't set them.
+const (
+	defaultSimpleStreamEncoder = "x264"
+	defaultAdvancedEncoder     = "obs_x264"
+	defaultStreamBitrate       = 2500
+)
+
+type profileEncoderSnapshot struct {
+	Profile   string
+	EncoderID string
+	Bitrate   int
+}
+
+// profileStreamEncoder works out a profile's streaming encoder and its bitrate in kbps, given a
+// lookup into its basic.ini and the contents of its streamEncoder.json. In simple output mode,
+// encoders are named by the frontend, like "x264", rather than by their encoder ID.
+func profileStreamEncoder(get func(section, name string) string, encoderJSON []byte) (id string, bitrate int) {
+	bitrate = defaultStreamBitrate
+	if get("Output", "Mode") == "Advanced" {
+		id = get("AdvOut", "Encoder")
+		if id == "" {
+			id = defaultAdvancedEncoder
+		}
+		var settings struct {
+			Bitrate int `json:"bitrate"`
+		}
+		if json.Unmarshal(encoderJSON, &settings) == nil && settings.Bitrate > 0 {
+			bitrate = settings.Bitrate
+		}
+		return id, bitrate
+	}
+
+	id = get("SimpleOutput", "StreamEncoder")
+	if id == "" {
+		id = defaultSimpleStreamEncoder
+	}
+	if b, err := strconv.Atoi(get("SimpleOutput", "VBitrate")); err == nil && b > 0 {
+		bitrate = b
+	}
+	return id, bitrate
+}
+
+// configFile is a parsed libobs config file, like a profile's basic.ini, by section and then key.
+type configFile map[string]map[string]string
+
+// parseConfigFile parses the ini format libobs writes config files in.
+func parseConfigFile(data []byte) configFile {
+	cfg := configFile{}
+	var section map[string]string
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || line[0] == ';' || line[0] == '#':
+		case line[0] == '[' && line[len(line)-1] == ']':
+			name := line[1 : len(line)-1]
+			if cfg[name] == nil {
+				cfg[name] = map[string]string{}
+			}
+			section = cfg[name]
+		case section != nil:
+			if eq := strings.IndexByte(line, '='); eq >= 0 {
+				section[strings.TrimSpace(line[:eq])] = strings.TrimSpace(line[eq+1:])
+			}
+		}
+	}
+	return cfg
+}
+
+func (cfg configFile) get(section, name string) string {
+	return cfg[section][name]
+}
+
+// profileFiles caches the files read from every profile, which are read on every scrape.
+var profileFiles fileCache
+
+func parseConfigFileData(data []byte) (interface{}, error) {
+	return parseConfigFile(data), nil
+}
+
+func rawFileData(data []byte) (interface{}, error) {
+	return data, nil
+}
+
+// readProfileEncoder reads the streaming encoder from the profile stored in dir.
+func readProfileEncoder(dir string) (profileEncoderSnapshot, bool) {
+	v, err := profileFiles.get(filepath.Join(dir, "basic.ini"), parseConfigFileData)
+	if err != nil {
+		return profileEncoderSnapshot{}, false
+	}
+	cfg := v.(configFile)
+	// A missing file just means the encoder's settings haven't been changed.
+	var encoderJSON []byte
+	if v, err := profileFiles.get(filepath.Join(dir, "streamEncoder.json"), rawFileData); err == nil {
+		encoderJSON = v.([]byte)
+	}
+	id, bitrate := profileStreamEncoder(cfg.get, encoderJSON)
+	return profileEncoderSnapshot{
+		Profile:   cfg.get("General", "Name"),
+		EncoderID: id,
+		Bitrate:   bitrate,
+	}, true
+}
+
+// profilesDir returns the directory every profile is stored in, next to the current one. It must
+// be called with obsLock held.
+func profilesDir() (string, bool) {
+	current := frontendCurrentProfilePath()
+	if current == nil {
+		return "", false
+	}
+	defer C.bfree(unsafe.Pointer(current))
+	return filepath.Dir(filepath.Clean(C.GoString(current))), true
+}
+
+// readProfileEncoders reads the streaming encoder of every profile in dir from disk, only reading
+// the files of profiles that have changed since the last scrape. It doesn't call into OBS, so
+// it's done without holding obsLock.
+func readProfileEncoders(dir string) []profileEncoderSnapshot {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var snaps []profileEncoderSnapshot
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if snap, ok := readProfileEncoder(filepath.Join(dir, e.Name())); ok {
+			snaps = append(snaps, snap)
+		}
+	}
+	return snaps
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeProfile writes a profile's config files into a new directory.
+func writeProfile(t *testing.T, basicINI, streamEncoderJSON string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "basic.ini"), []byte(basicINI), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if streamEncoderJSON != "" {
+		if err := os.WriteFile(filepath.Join(dir, "streamEncoder.json"), []byte(streamEncoderJSON), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadProfileEncoder(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		basicINI          string
+		streamEncoderJSON string
+		want              profileEncoderSnapshot
+	}{{
+		name: "advanced",
+		// libobs writes config files with a byte order mark.
+		basicINI:          "\xef\xbb\xbf[General]\r\nName=Twitch\r\n\r\n[Output]\r\nMode=Advanced\r\n\r\n[AdvOut]\r\nEncoder=jim_nvenc\r\nRecEncoder=jim_hevc_nvenc\r\n",
+		streamEncoderJSON: `{"bitrate":6000,"preset2":"p5"}`,
+		want:              profileEncoderSnapshot{Profile: "Twitch", EncoderID: "jim_nvenc", Bitrate: 6000},
+	}, {
+		name:     "simple",
+		basicINI: "[General]\nName=Recording\n\n[Output]\nMode=Simple\n\n[SimpleOutput]\nStreamEncoder=nvenc\nVBitrate=4500\n",
+		want:     profileEncoderSnapshot{Profile: "Recording", EncoderID: "nvenc", Bitrate: 4500},
+	}, {
+		name:     "defaults",
+		basicINI: "[General]\nName=Untitled\n",
+		want:     profileEncoderSnapshot{Profile: "Untitled", EncoderID: defaultSimpleStreamEncoder, Bitrate: defaultStreamBitrate},
+	}} {
+		got, ok := readProfileEncoder(writeProfile(t, tc.basicINI, tc.streamEncoderJSON))
+		if !ok || got != tc.want {
+			t.Errorf("%s: readProfileEncoder = %+v, %v; want %+v, true", tc.name, got, ok, tc.want)
+		}
+	}
+
+	if _, ok := readProfileEncoder(t.TempDir()); ok {
+		t.Error("readProfileEncoder succeeded for a directory without a basic.ini")
+	}
+}
+
+func TestParseConfigFile(t *testing.T) {
+	cfg := parseConfigFile([]byte("ignored=outside a section\n[General]\n; a comment\nName = Spaced Out \n[Output]\nMode=Advanced\nURL=rtmp://example.com/live?key=a=b\n"))
+	for _, tc := range []struct{ section, name, want string }{
+		{"General", "Name", "Spaced Out"},
+		{"Output", "Mode", "Advanced"},
+		{"Output", "URL", "rtmp://example.com/live?key=a=b"},
+		{"Output", "Missing", ""},
+		{"Missing", "Mode", ""},
+		{"", "ignored", ""},
+	} {
+		if got := cfg.get(tc.section, tc.name); got != tc.want {
+			t.Errorf("get(%q, %q) = %q, want %q", tc.section, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestReadProfileEncoders(t *testing.T) {
+	dir := t.TempDir()
+	for name, basicINI := range map[string]string{
+		"Twitch":    "[General]\nName=Twitch\n\n[Output]\nMode=Simple\n\n[SimpleOutput]\nStreamEncoder=nvenc\nVBitrate=6000\n",
+		"Recording": "[General]\nName=Recording\n",
+	} {
+		if err := os.Rename(writeProfile(t, basicINI, ""), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Anything else next to the profiles is skipped.
+	if err := os.Mkdir(filepath.Join(dir, "Empty"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "basic.ini"), []byte("[General]\nName=Stray\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]profileEncoderSnapshot{}
+	for _, p := range readProfileEncoders(dir) {
+		got[p.Profile] = p
+	}
+	want := map[string]profileEncoderSnapshot{
+		"Twitch":    {Profile: "Twitch", EncoderID: "nvenc", Bitrate: 6000},
+		"Recording": {Profile: "Recording", EncoderID: defaultSimpleStreamEncoder, Bitrate: defaultStreamBitrate},
+	}
+	if len(got) != len(want) {
+		t.Errorf("readProfileEncoders = %+v, want %+v", got, want)
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("readProfileEncoders for %s = %+v, want %+v", name, got[name], w)
+		}
+	}
+
+	if got := readProfileEncoders(filepath.Join(dir, "missing")); got != nil {
+		t.Errorf("readProfileEncoders of a missing directory = %+v, want nil", got)
+	}
+}
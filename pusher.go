@@ -0,0 +1,86 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const (
+	pushJobName = "obs_studio"
+	// Upper bound on the delay between pushes while the Pushgateway is failing.
+	pushBackoffMax = 5 * time.Minute
+)
+
+var (
+	pushFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: exporterSubsystem,
+		Name:      "push_failures_total",
+		Help:      "Failed pushes to the Pushgateway.",
+	})
+	pushLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: exporterSubsystem,
+		Name:      "push_last_success_timestamp_seconds",
+		Help:      "Time of the last successful push to the Pushgateway.",
+	})
+)
+
+// pushBackoff returns how long to wait before the next push, given the number of consecutive failures so far.
+// The delay doubles with each failure, starting at interval, up to pushBackoffMax.
+func pushBackoff(interval time.Duration, failures int) time.Duration {
+	d := interval
+	for n := 0; n < failures && d < pushBackoffMax; n++ {
+		d *= 2
+	}
+	if failures > 0 && d > pushBackoffMax {
+		d = pushBackoffMax
+	}
+	return d
+}
+
+func runPusher(ctx context.Context, url string, interval time.Duration) {
+	pusher := push.New(url, pushJobName).Gatherer(exportedMetrics)
+	failures := 0
+	for {
+		heartbeat(ctx, pushBackoff(interval, failures))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pushBackoff(interval, failures)):
+		}
+
+		if err := pusher.PushContext(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			failures++
+			pushFailures.Inc()
+			slog.Warn("push to Pushgateway failed", "url", url, "consecutive_failures", failures, "next_attempt", pushBackoff(interval, failures), "err", err)
+			continue
+		}
+		if failures > 0 {
+			slog.Info("push to Pushgateway recovered", "url", url, "consecutive_failures", failures)
+		}
+		failures = 0
+		pushLastSuccess.SetToCurrentTime()
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPushBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		interval time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{15 * time.Second, 0, 15 * time.Second},
+		{15 * time.Second, 1, 30 * time.Second},
+		{15 * time.Second, 2, time.Minute},
+		{15 * time.Second, 4, 4 * time.Minute},
+		{15 * time.Second, 5, pushBackoffMax},
+		{15 * time.Second, 100, pushBackoffMax},
+		// A configured interval above the cap is still used while pushes succeed.
+		{10 * time.Minute, 0, 10 * time.Minute},
+		{10 * time.Minute, 1, pushBackoffMax},
+	} {
+		if got := pushBackoff(tc.interval, tc.failures); got != tc.want {
+			t.Errorf("pushBackoff(%v, %d) = %v, want %v", tc.interval, tc.failures, got, tc.want)
+		}
+	}
+}
+
+func TestPusherCountsFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	before := testutil.ToFloat64(pushFailures)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runPusher(ctx, srv.URL, time.Millisecond)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(pushFailures)-before < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("push_failures_total went up by %v, want at least 2", testutil.ToFloat64(pushFailures)-before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
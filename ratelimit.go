@@ -0,0 +1,50 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// logLimiter allows one event per key per interval, so that noisy log lines don't flood the OBS log.
+type logLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func newLogLimiter(interval time.Duration) *logLimiter {
+	return &logLimiter{
+		interval: interval,
+		last:     map[string]time.Time{},
+	}
+}
+
+func (l *logLimiter) allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if last, ok := l.last[key]; ok && now.Sub(last) < l.interval {
+		return false
+	}
+	l.last[key] = now
+	for k, last := range l.last {
+		if now.Sub(last) >= l.interval {
+			delete(l.last, k)
+		}
+	}
+	return true
+}
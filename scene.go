@@ -0,0 +1,214 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <obs.h>
+
+typedef bool (*mc_enum_scenes_proc)(void*, obs_source_t*);
+typedef bool (*mc_enum_scene_items_proc)(obs_scene_t*, obs_sceneitem_t*, void*);
+
+bool mc_enum_scenes_cb(void*, obs_source_t*);
+bool mc_enum_scene_items_cb(obs_scene_t*, obs_sceneitem_t*, void*);
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+type sceneSnapshot struct {
+	Name           string
+	UUID           string
+	MissingSources int
+	// Items is the number of items directly in the scene, counting each group as one.
+	Items int
+
+	// HasFrontend is set if OBS's frontend is running, so Program and Preview could be worked out.
+	HasFrontend bool
+	// Program is set for the scene being output, and Preview for the scene in studio mode's preview.
+	Program bool
+	Preview bool
+}
+
+// frontendSceneInfo describes the program or preview scene. It's empty if there's no such scene.
+type frontendSceneInfo struct {
+	Name          string
+	Width, Height uint32
+}
+
+// frontendScene describes a scene the frontend returned, and releases it.
+func frontendScene(s *C.obs_source_t) frontendSceneInfo {
+	if s == nil {
+		return frontendSceneInfo{}
+	}
+	defer C.obs_source_release(s)
+	return frontendSceneInfo{
+		Name:   C.GoString(C.obs_source_get_name(s)),
+		Width:  uint32(C.obs_source_get_width(s)),
+		Height: uint32(C.obs_source_get_height(s)),
+	}
+}
+
+// currentScenes returns the program scene and, in studio mode, the preview scene.
+// It must only be called if the frontend is available.
+func currentScenes() (program, preview frontendSceneInfo, studioMode bool) {
+	program = frontendScene(frontendCurrentScene())
+	studioMode = frontendStudioMode()
+	// This is nil unless studio mode is on.
+	preview = frontendScene(frontendCurrentPreviewScene())
+	return program, preview, studioMode
+}
+
+type groupSnapshot struct {
+	Name    string
+	Members int
+}
+
+// sceneItemMissing reports whether a scene item points at a source that no longer exists.
+func sceneItemMissing(item *C.obs_sceneitem_t) bool {
+	src := C.obs_sceneitem_get_source(item)
+	return src == nil || bool(C.obs_source_removed(src))
+}
+
+// sceneTree is a scene and everything in it, copied out of OBS so it can be tallied without holding on to OBS's objects.
+type sceneTree struct {
+	Name  string
+	UUID  string
+	Items []sceneTreeItem
+}
+
+type sceneTreeItem struct {
+	// Missing is set if the item points at a source that no longer exists, in which case nothing else is set.
+	Missing bool
+	UUID    string
+	// IsGroup is set if the item is a group, in which case Name is the group's name and Items is what's directly inside it.
+	IsGroup bool
+	Name    string
+	Items   []sceneTreeItem
+}
+
+// readScenes copies every scene, and the groups inside them, out of OBS. Groups themselves aren't
+// returned as scenes, since they're part of the scenes they're in.
+func (c *MetricCollector) readScenes() []sceneTree {
+	var scenes []sceneTree
+	// items is where the items being enumerated go: the current scene, or the group being enumerated.
+	var items *[]sceneTreeItem
+	c.enumSceneItemsCB = func(scene *C.obs_scene_t, item *C.obs_sceneitem_t, v unsafe.Pointer) C.bool {
+		if sceneItemMissing(item) {
+			*items = append(*items, sceneTreeItem{Missing: true})
+			return C.bool(true)
+		}
+		src := C.obs_sceneitem_get_source(item)
+		ti := sceneTreeItem{UUID: C.GoString(C.obs_source_get_uuid(src))}
+		if C.obs_sceneitem_is_group(item) {
+			ti.IsGroup = true
+			ti.Name = C.GoString(C.obs_source_get_name(src))
+		}
+		*items = append(*items, ti)
+		if ti.IsGroup {
+			parent := items
+			items = &(*parent)[len(*parent)-1].Items
+			C.obs_sceneitem_group_enum_items(item, C.mc_enum_scene_items_proc(C.mc_enum_scene_items_cb), nil)
+			items = parent
+		}
+		return C.bool(true)
+	}
+	c.enumScenesCB = func(v unsafe.Pointer, o *C.obs_source_t) C.bool {
+		if C.obs_source_is_group(o) {
+			return C.bool(true)
+		}
+		scenes = append(scenes, sceneTree{
+			Name: C.GoString(C.obs_source_get_name(o)),
+			UUID: C.GoString(C.obs_source_get_uuid(o)),
+		})
+		items = &scenes[len(scenes)-1].Items
+		C.obs_scene_enum_items(C.obs_scene_from_source(o), C.mc_enum_scene_items_proc(C.mc_enum_scene_items_cb), nil)
+		return C.bool(true)
+	}
+	C.obs_enum_scenes(C.mc_enum_scenes_proc(C.mc_enum_scenes_cb), nil)
+	return scenes
+}
+
+// snapshotScenes reads the scenes from OBS and tallies them; see tallyScenes.
+func (c *MetricCollector) snapshotScenes(program, preview string, cfg *Config) ([]sceneSnapshot, []groupSnapshot, map[string]int) {
+	return tallyScenes(c.readScenes(), program, preview, frontendAvailable, cfg.Groups, cfg.SceneReferences)
+}
+
+// tallyScenes counts the missing sources in each scene, including those inside groups,
+// and marks the program and preview scenes from currentScenes.
+// If withGroups is set, it also returns the groups found in every scene, including groups
+// nested inside other groups, with the number of items directly inside each. If withRefs is
+// set, it returns how many scenes each source is in, keyed by UUID.
+func tallyScenes(scenes []sceneTree, program, preview string, hasFrontend, withGroups, withRefs bool) ([]sceneSnapshot, []groupSnapshot, map[string]int) {
+	var snaps []sceneSnapshot
+	var groups []groupSnapshot
+	seenGroups := map[string]bool{}
+	var refs map[string]int
+	if withRefs {
+		refs = map[string]int{}
+	}
+	for _, scene := range scenes {
+		// inScene is the sources already counted for this scene, so a source that's in
+		// a scene more than once only counts once.
+		inScene := map[string]bool{}
+		missing := 0
+		var walk func(items []sceneTreeItem)
+		walk = func(items []sceneTreeItem) {
+			for _, item := range items {
+				if item.Missing {
+					missing++
+					continue
+				}
+				if refs != nil && !inScene[item.UUID] {
+					inScene[item.UUID] = true
+					refs[item.UUID]++
+				}
+				if item.IsGroup {
+					// A group is one source, however many times it's been added.
+					if withGroups && !seenGroups[item.UUID] {
+						seenGroups[item.UUID] = true
+						groups = append(groups, groupSnapshot{Name: item.Name, Members: len(item.Items)})
+					}
+					walk(item.Items)
+				}
+			}
+		}
+		walk(scene.Items)
+		snaps = append(snaps, sceneSnapshot{
+			Name:           scene.Name,
+			UUID:           scene.UUID,
+			MissingSources: missing,
+			Items:          len(scene.Items),
+			HasFrontend:    hasFrontend,
+			Program:        scene.Name != "" && scene.Name == program,
+			Preview:        scene.Name != "" && scene.Name == preview,
+		})
+	}
+	return snaps, groups, refs
+}
+
+//export mc_enum_scenes_cb_go
+func mc_enum_scenes_cb_go(f unsafe.Pointer, s *C.obs_source_t) (ret C.bool) {
+	defer recoverCollectPanic(&ret)
+	return activeMetricCollector.enumScenesCB(f, s)
+}
+
+//export mc_enum_scene_items_cb_go
+func mc_enum_scene_items_cb_go(scene *C.obs_scene_t, item *C.obs_sceneitem_t, f unsafe.Pointer) (ret C.bool) {
+	defer recoverCollectPanic(&ret)
+	return activeMetricCollector.enumSceneItemsCB(scene, item, f)
+}
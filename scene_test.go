@@ -0,0 +1,197 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestTallyScenesCountsMissingSources(t *testing.T) {
+	scenes := []sceneTree{{
+		Name: "Live",
+		Items: []sceneTreeItem{
+			{UUID: "uuid-camera"},
+			{Missing: true},
+			{UUID: "uuid-overlays", IsGroup: true, Name: "Overlays", Items: []sceneTreeItem{
+				{UUID: "uuid-logo"},
+				{Missing: true},
+			}},
+		},
+	}, {
+		Name:  "BRB",
+		Items: []sceneTreeItem{{UUID: "uuid-camera"}},
+	}}
+
+	snaps, _, _ := tallyScenes(scenes, "", "", false, false, false)
+	want := map[string]int{"Live": 2, "BRB": 0}
+	if len(snaps) != len(want) {
+		t.Fatalf("tallyScenes returned %d scenes, want %d", len(snaps), len(want))
+	}
+	for _, s := range snaps {
+		if s.MissingSources != want[s.Name] {
+			t.Errorf("scene %q has %d missing sources, want %d", s.Name, s.MissingSources, want[s.Name])
+		}
+	}
+}
+
+func TestTallyScenesCountsGroupMembers(t *testing.T) {
+	nested := sceneTreeItem{UUID: "uuid-lower-thirds", IsGroup: true, Name: "Lower thirds", Items: []sceneTreeItem{
+		{UUID: "uuid-name"},
+		{UUID: "uuid-title"},
+		{UUID: "uuid-bar"},
+	}}
+	overlays := sceneTreeItem{UUID: "uuid-overlays", IsGroup: true, Name: "Overlays", Items: []sceneTreeItem{
+		{UUID: "uuid-logo"},
+		nested,
+	}}
+	scenes := []sceneTree{
+		{Name: "Live", Items: []sceneTreeItem{{UUID: "uuid-camera"}, overlays}},
+		{Name: "Interview", Items: []sceneTreeItem{overlays}},
+	}
+
+	_, groups, _ := tallyScenes(scenes, "", "", false, true, false)
+	got := map[string]int{}
+	for _, g := range groups {
+		if _, ok := got[g.Name]; ok {
+			t.Errorf("group %q returned more than once", g.Name)
+		}
+		got[g.Name] = g.Members
+	}
+	// Members are what's directly inside a group, so a nested group counts as one.
+	want := map[string]int{"Overlays": 2, "Lower thirds": 3}
+	if len(got) != len(want) {
+		t.Errorf("tallyScenes returned groups %v, want %v", got, want)
+	}
+	for name, members := range want {
+		if got[name] != members {
+			t.Errorf("group %q has %d members, want %d", name, got[name], members)
+		}
+	}
+
+	if _, groups, _ := tallyScenes(scenes, "", "", false, false, false); groups != nil {
+		t.Errorf("tallyScenes returned groups %v with groups disabled", groups)
+	}
+}
+
+func TestEmitSceneDimensionsOnlyInStudioMode(t *testing.T) {
+	c := newTestCollector(t)
+	dimensions := []string{
+		"obs_frontend_program_width", "obs_frontend_program_height",
+		"obs_frontend_preview_width", "obs_frontend_preview_height",
+	}
+	snapshot := func(studioMode bool) *collectorSnapshot {
+		snap := &collectorSnapshot{Up: true}
+		snap.Global.HasFrontend = true
+		snap.Global.StudioMode = studioMode
+		snap.Global.ProgramScene = frontendSceneInfo{Name: "Live", Width: 1920, Height: 1080}
+		if studioMode {
+			snap.Global.PreviewScene = frontendSceneInfo{Name: "Vertical", Width: 1080, Height: 1920}
+		}
+		return snap
+	}
+
+	ms := emitSnapshot(t, c, snapshot(false))
+	for _, name := range dimensions {
+		if _, ok := findMetric(ms, name, nil); ok {
+			t.Errorf("%s emitted outside studio mode", name)
+		}
+	}
+	if _, ok := findMetric(ms, "obs_frontend_current_program_scene", map[string]string{"scene_name": "Live"}); !ok {
+		t.Error("program scene not emitted outside studio mode")
+	}
+
+	ms = emitSnapshot(t, c, snapshot(true))
+	for name, want := range map[string]float64{
+		"obs_frontend_program_width": 1920, "obs_frontend_program_height": 1080,
+		"obs_frontend_preview_width": 1080, "obs_frontend_preview_height": 1920,
+	} {
+		if m, ok := findMetric(ms, name, nil); !ok || m.Value != want {
+			t.Errorf("in studio mode, %s = %v, %v, want %v", name, m.Value, ok, want)
+		}
+	}
+}
+
+func TestTallyScenesCountsReferences(t *testing.T) {
+	overlays := sceneTreeItem{UUID: "uuid-overlays", IsGroup: true, Name: "Overlays", Items: []sceneTreeItem{
+		{UUID: "uuid-logo"},
+		{UUID: "uuid-chat"},
+	}}
+	scenes := []sceneTree{{
+		Name: "Live",
+		Items: []sceneTreeItem{
+			{UUID: "uuid-camera"},
+			// The same source twice in one scene is still just one scene referencing it.
+			{UUID: "uuid-camera"},
+			{UUID: "uuid-game"},
+			overlays,
+		},
+	}, {
+		Name: "BRB",
+		Items: []sceneTreeItem{
+			{UUID: "uuid-brb-video"},
+			overlays,
+			{Missing: true},
+		},
+	}, {
+		Name:  "Camera only",
+		Items: []sceneTreeItem{{UUID: "uuid-camera"}},
+	}}
+
+	_, _, refs := tallyScenes(scenes, "Live", "", true, false, true)
+	want := map[string]int{
+		"uuid-camera":    2,
+		"uuid-game":      1,
+		"uuid-overlays":  2,
+		"uuid-logo":      2,
+		"uuid-chat":      2,
+		"uuid-brb-video": 1,
+	}
+	if len(refs) != len(want) {
+		t.Errorf("references counted for %d sources, want %d: %v", len(refs), len(want), refs)
+	}
+	for uuid, n := range want {
+		if refs[uuid] != n {
+			t.Errorf("%s is referenced by %d scenes, want %d", uuid, refs[uuid], n)
+		}
+	}
+
+	if _, _, refs := tallyScenes(scenes, "Live", "", true, false, false); refs != nil {
+		t.Errorf("references counted without being asked for: %v", refs)
+	}
+}
+
+func TestEmitSceneReferencesOnlyWhenEnabled(t *testing.T) {
+	snap := &collectorSnapshot{
+		Up: true,
+		Sources: []sourceSnapshot{
+			{UUID: "uuid-camera", Name: "Camera"},
+			{UUID: "uuid-orphan", Name: "Old Webcam"},
+		},
+		SceneReferences: map[string]int{"uuid-camera": 2},
+	}
+	const metric = "obs_source_scene_reference_count"
+
+	ms := emitSnapshot(t, newTestCollector(t), snap)
+	if _, ok := findMetric(ms, metric, nil); ok {
+		t.Errorf("%s emitted without scene references enabled", metric)
+	}
+
+	cfg := defaultConfig()
+	cfg.SceneReferences = true
+	ms = emitSnapshot(t, newTestCollectorWithConfig(t, cfg), snap)
+	for name, want := range map[string]float64{"Camera": 2, "Old Webcam": 0} {
+		if m, ok := findMetric(ms, metric, map[string]string{"source_name": name}); !ok || m.Value != want {
+			t.Errorf("%s{source_name=%q} = %v, %v, want %v", metric, name, m.Value, ok, want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <stdlib.h>
+#include <obs-module.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// The settings file lives in the plugin's config directory, e.g.
+// ~/.config/obs-studio/plugin_config/obs-studio-exporter/settings.json. It has a key for
+// each environment variable, which take precedence over it; see settingsKey.
+const (
+	settingsFile      = "settings.json"
+	settingsBackupExt = "bak"
+	settingsTempExt   = "tmp"
+
+	// settingsPollInterval is how often the settings file is checked for changes.
+	settingsPollInterval = 5 * time.Second
+)
+
+// settingsFilePath returns the path of the settings file, which may not exist yet.
+func settingsFilePath() string {
+	if obsModulePointer == nil {
+		return ""
+	}
+	fileC := C.CString(settingsFile)
+	defer C.free(unsafe.Pointer(fileC))
+	path := C.obs_module_get_config_path(obsModulePointer, fileC)
+	if path == nil {
+		return ""
+	}
+	defer C.bfree(unsafe.Pointer(path))
+	return C.GoString(path)
+}
+
+// settingsStore holds the settings file's JSON.
+type settingsStore interface {
+	// Load returns the JSON in the settings file, or "" if there isn't one.
+	Load() string
+	// Save replaces the settings file with s.
+	Save(s string) error
+	// String describes where the settings are kept, for logging.
+	String() string
+}
+
+// obsSettingsFile keeps the settings in a file using OBS's data API, like OBS keeps its own settings.
+type obsSettingsFile string
+
+func (f obsSettingsFile) Load() string {
+	pathC := C.CString(string(f))
+	defer C.free(unsafe.Pointer(pathC))
+	extC := C.CString(settingsBackupExt)
+	defer C.free(unsafe.Pointer(extC))
+	// This falls back to the backup OBS keeps when it writes the file, if the file itself is corrupt.
+	return obsDataJSON(C.obs_data_create_from_json_file_safe(pathC, extC))
+}
+
+func (f obsSettingsFile) Save(s string) error {
+	// The plugin's config directory doesn't exist until someone puts something in it.
+	if err := os.MkdirAll(filepath.Dir(string(f)), 0o755); err != nil {
+		return err
+	}
+	jsonC := C.CString(s)
+	defer C.free(unsafe.Pointer(jsonC))
+	data := C.obs_data_create_from_json(jsonC)
+	if data == nil {
+		return errors.New("invalid settings JSON")
+	}
+	defer C.obs_data_release(data)
+	pathC := C.CString(string(f))
+	defer C.free(unsafe.Pointer(pathC))
+	tempExtC := C.CString(settingsTempExt)
+	defer C.free(unsafe.Pointer(tempExtC))
+	backupExtC := C.CString(settingsBackupExt)
+	defer C.free(unsafe.Pointer(backupExtC))
+	// This writes to a temporary file and renames it over the old one, keeping that as a backup.
+	if !C.obs_data_save_json_safe(data, pathC, tempExtC, backupExtC) {
+		return fmt.Errorf("writing %s failed", string(f))
+	}
+	return nil
+}
+
+func (f obsSettingsFile) String() string { return string(f) }
+
+// loadSettingsFile returns the values in the settings file, or nil if there isn't one, after
+// saving any settings given in the environment to it.
+func loadSettingsFile() map[string]string {
+	path := settingsFilePath()
+	if path == "" {
+		return nil
+	}
+	store := obsSettingsFile(path)
+	return saveEnvironmentSettings(store, loadSettings(store))
+}
+
+// loadSettings returns the values in store, or nil if it's empty or invalid.
+func loadSettings(store settingsStore) map[string]string {
+	s := store.Load()
+	if s == "" {
+		return nil
+	}
+	settings, err := parseSettings(s)
+	if err != nil {
+		countError(errorConfigParse)
+		slog.Warn("invalid settings file, ignoring it", "path", store.String(), "err", err)
+		return nil
+	}
+	slog.Info("loaded settings file", "path", store.String())
+	return settings
+}
+
+// saveEnvironmentSettings adds the settings given in environment variables to settings, and saves
+// them to store if that changed anything, so they're kept when OBS is next started without them.
+// It returns the updated settings, which are left as they were if they couldn't be saved.
+func saveEnvironmentSettings(store settingsStore, settings map[string]string) map[string]string {
+	merged := make(map[string]string, len(settings))
+	for k, v := range settings {
+		merged[k] = v
+	}
+	changed := false
+	for _, kv := range os.Environ() {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 || !strings.HasPrefix(kv[:i], "OBS_EXPORTER_") || kv[i+1:] == "" {
+			continue
+		}
+		key, v := settingsKey(kv[:i]), kv[i+1:]
+		if old, ok := merged[key]; !ok || !bytes.Equal(settingValue(old), settingValue(v)) {
+			merged[key] = v
+			changed = true
+		}
+	}
+	if !changed {
+		return settings
+	}
+	if err := store.Save(settingsJSON(merged)); err != nil {
+		countError(errorConfigSave)
+		slog.Warn("saving settings file failed", "path", store.String(), "err", err)
+		return settings
+	}
+	slog.Info("saved settings from the environment to the settings file", "path", store.String())
+	return merged
+}
+
+// settingsJSON is the inverse of parseSettings.
+func settingsJSON(settings map[string]string) string {
+	raw := make(map[string]json.RawMessage, len(settings))
+	for k, v := range settings {
+		raw[k] = settingValue(v)
+	}
+	b, _ := json.MarshalIndent(raw, "", "  ")
+	return string(b)
+}
+
+// settingValue returns how a setting is written in the settings file. Values that are JSON
+// numbers, booleans, objects or arrays are written as JSON, so settings like
+// OBS_EXPORTER_LISTENERS stay readable, and everything else as a string.
+func settingValue(v string) json.RawMessage {
+	if t := strings.TrimSpace(v); t != "" && t[0] != '"' && json.Valid([]byte(t)) {
+		var b bytes.Buffer
+		if err := json.Compact(&b, []byte(t)); err == nil {
+			return b.Bytes()
+		}
+	}
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// settingsModTime returns when the file at path was last changed, or the zero time if it doesn't exist.
+func settingsModTime(path string) time.Time {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+// watchSettings reloads the config whenever the settings file at path is changed, created or removed.
+func watchSettings(ctx context.Context, path string) {
+	last := settingsModTime(path)
+	ticker := time.NewTicker(settingsPollInterval)
+	defer ticker.Stop()
+	for {
+		heartbeat(ctx, settingsPollInterval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if t := settingsModTime(path); !t.Equal(last) {
+			last = t
+			slog.Info("settings file changed, reloading settings", "path", path)
+			reloadConfig()
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeSettingsStore keeps the settings file's JSON in memory.
+type fakeSettingsStore struct {
+	json  string
+	saves int
+	err   error
+}
+
+func (s *fakeSettingsStore) Load() string { return s.json }
+
+func (s *fakeSettingsStore) Save(json string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.json = json
+	s.saves++
+	return nil
+}
+
+func (s *fakeSettingsStore) String() string { return "settings.json" }
+
+// clearExporterEnv unsets every OBS_EXPORTER_ environment variable until the test ends.
+func clearExporterEnv(t *testing.T) {
+	t.Helper()
+	for _, kv := range os.Environ() {
+		if name := kv[:strings.IndexByte(kv, '=')]; strings.HasPrefix(name, "OBS_EXPORTER_") {
+			t.Setenv(name, "")
+		}
+	}
+}
+
+func TestSettingsJSONRoundTrip(t *testing.T) {
+	settings := map[string]string{
+		"port":                 "9407",
+		"groups":               "true",
+		"push_interval":        "30s",
+		"listeners":            `[{"address": "127.0.0.1", "port": 9407}]`,
+		"source_name_template": `{{.Type}}/{{.Name}}`,
+		"logtail_password":     `"quoted" password`,
+		"pushgateway_url":      "http://localhost:9091",
+	}
+	got, err := parseSettings(settingsJSON(settings))
+	if err != nil {
+		t.Fatalf("parseSettings(settingsJSON(...)): %v", err)
+	}
+	if !sameSettings(got, settings) {
+		t.Errorf("settings after a round trip = %v, want %v", got, settings)
+	}
+}
+
+// sameSettings reports whether a and b have the same settings, ignoring how JSON values are formatted.
+func sameSettings(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		w, ok := b[k]
+		if !ok || string(settingValue(v)) != string(settingValue(w)) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSaveEnvironmentSettings(t *testing.T) {
+	clearExporterEnv(t)
+	recordLogs(t)
+	store := &fakeSettingsStore{json: `{"groups": true, "listeners": [{"port": 9408}]}`}
+
+	// Settings that are only in the file are left alone.
+	if got := saveEnvironmentSettings(store, loadSettings(store)); store.saves != 0 || got["groups"] != "true" {
+		t.Errorf("with nothing in the environment, saved %d times and got %v", store.saves, got)
+	}
+
+	t.Setenv(envPort, "9420")
+	t.Setenv(envLogTailPassword, "hunter2")
+	// The same listeners, written differently, aren't a change.
+	t.Setenv(envListeners, `[ {"port":9408} ]`)
+	got := saveEnvironmentSettings(store, loadSettings(store))
+	if store.saves != 1 {
+		t.Fatalf("saved %d times, want once", store.saves)
+	}
+	want := map[string]string{
+		"groups":           "true",
+		"port":             "9420",
+		"logtail_password": "hunter2",
+		"listeners":        `[{"port": 9408}]`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("settings after saving the environment = %v, want %v", got, want)
+	}
+
+	// Reading the file back gets the same settings, and doesn't save them again.
+	reread := loadSettings(store)
+	if !sameSettings(reread, want) {
+		t.Errorf("settings read back = %v, want %v", reread, want)
+	}
+	saveEnvironmentSettings(store, reread)
+	if store.saves != 1 {
+		t.Errorf("saved %d times after reading the file back, want once", store.saves)
+	}
+
+	// If the file can't be written, the settings from the file are used as they are.
+	failing := &fakeSettingsStore{json: `{"groups": true}`, err: errors.New("read-only file system")}
+	if got := saveEnvironmentSettings(failing, loadSettings(failing)); !reflect.DeepEqual(got, map[string]string{"groups": "true"}) {
+		t.Errorf("settings after a failed save = %v, want just the file's", got)
+	}
+}
+
+func TestLoadSettingsInvalidFile(t *testing.T) {
+	recordLogs(t)
+	if got := loadSettings(&fakeSettingsStore{}); got != nil {
+		t.Errorf("loadSettings with no file = %v, want nil", got)
+	}
+	if got := loadSettings(&fakeSettingsStore{json: `[1, 2]`}); got != nil {
+		t.Errorf("loadSettings of an invalid file = %v, want nil", got)
+	}
+}
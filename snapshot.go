@@ -0,0 +1,216 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+)
+
+// collectorSnapshot is everything Collect needs from OBS. It's taken while holding obsLock,
+// and metrics are then emitted from it after the lock is released, so that a slow consumer
+// of the metrics channel can't stall OBS.
+type collectorSnapshot struct {
+	// Up is false if OBS is shutting down, in which case nothing else is filled in.
+	Up bool
+
+	Global  globalSnapshot
+	Sources []sourceSnapshot
+	// SourcesTruncated is set if Sources was cut short by the configured maximum.
+	SourcesTruncated bool
+	// AudioBufferBytes is the memory held by the tracked sources' circular level buffers.
+	AudioBufferBytes float64
+	Outputs          []outputSnapshot
+	Encoders         []encoderSnapshot
+	Scenes           []sceneSnapshot
+	// Groups is only filled in if enabled in the config.
+	Groups []groupSnapshot
+	// SceneReferences is the number of scenes each source is in, keyed by UUID.
+	// It's only filled in if enabled in the config.
+	SceneReferences map[string]int
+	// ProfileEncoders is only filled in if enabled in the config.
+	ProfileEncoders []profileEncoderSnapshot
+}
+
+type globalSnapshot struct {
+	ActiveFPS          float64
+	TargetFPS          float64
+	AverageFrameTimeNS float64
+	TotalFrames        float64
+	LaggedFrames       float64
+	VideoTotalFrames   float64
+	VideoSkippedFrames float64
+	MemoryAllocations  float64
+	ActiveFilters      int
+	Canvas             canvasSnapshot
+	HasCanvas          bool
+	SDRWhiteNits       float64
+
+	PortableMode bool
+	// HasFrontend is set if OBS's frontend is running, so SafeMode and the profile settings below could be read.
+	HasFrontend     bool
+	SafeMode        bool
+	OutputMode      string
+	StreamingActive bool
+	RecordingActive bool
+	RecordingPaused bool
+	// ProgramScene and PreviewScene are empty if there's no such scene; PreviewScene is only set in studio mode.
+	ProgramScene frontendSceneInfo
+	PreviewScene frontendSceneInfo
+	StudioMode   bool
+
+	// HasReplayBuffer is set if the replay buffer is enabled in the current profile.
+	HasReplayBuffer    bool
+	ReplayBufferLength float64
+
+	MonitoringDeviceName string
+	MonitoringDeviceID   string
+
+	// HasAudioInfo is set if OBS's audio output has been set up.
+	HasAudioInfo    bool
+	AudioSampleRate float64
+	AudioSpeakers   float64
+
+	WebSocketLoaded  bool
+	WebSocketEnabled bool
+}
+
+type sourceSnapshot struct {
+	ID   string
+	UUID string
+	Name string
+
+	IsAudio   bool
+	Balance   float64
+	LatencyNS float64
+	Mixers    uint32
+	// PushToTalk and PushToMute are whether the source has push-to-talk or push-to-mute turned on.
+	PushToTalk bool
+	PushToMute bool
+	// AudioFilters is only filled in if enabled in the config.
+	AudioFilters []audioFilterSnapshot
+
+	IsVideo bool
+	Width   uint32
+	Height  uint32
+	Frozen  bool
+
+	// HasGlobalChannel is set if this source is a global audio device.
+	HasGlobalChannel bool
+	GlobalChannel    int
+
+	SettingsHash  uint32
+	CaptureTarget string
+
+	// Meter is nil until the source has been seen by a previous scrape.
+	Meter *sourceMeterSnapshot
+}
+
+type sourceMeterSnapshot struct {
+	VolumeChanges   uint64
+	VolMeterUpdates uint64
+	Channels        []channelSnapshot
+}
+
+// channelSnapshot holds the maximum levels over the circular buffer for a single audio channel.
+type channelSnapshot struct {
+	Magnitude float64
+	Peak      float64
+	InputPeak float64
+	// MagnitudeTime, PeakTime and InputPeakTime are when each maximum was sampled.
+	MagnitudeTime time.Time
+	PeakTime      time.Time
+	InputPeakTime time.Time
+
+	Clipping uint64
+	// SessionPeak is the highest peak since the source was first seen.
+	SessionPeak float64
+	PeakHold    float64
+}
+
+type outputSnapshot struct {
+	ID          string
+	Name        string
+	DisplayName string
+	Kind        string
+	ServerHost  string
+
+	Active         bool
+	Reconnecting   bool
+	TotalBytes     float64
+	DroppedFrames  float64
+	TotalFrames    float64
+	Width          float64
+	Height         float64
+	Congestion     float64
+	ConnectTime    float64
+	SessionDropped float64
+	NetworkDropped float64
+
+	// HasVideo is set if the output has a video pipeline, in which case VideoSkippedFrames is its skipped frame count.
+	HasVideo           bool
+	VideoSkippedFrames float64
+	// HasReconnectDelay is set while the output is reconnecting, if we saw when its next attempt is due.
+	HasReconnectDelay       bool
+	ReconnectDelayRemaining float64
+	// Rescaling is set if the output is scaled from the canvas's output size, using ScaleType.
+	Rescaling bool
+	ScaleType string
+
+	// EncodesVideo and EncodesAudio are set if the output needs the corresponding encoder.
+	EncodesVideo    bool
+	EncodesAudio    bool
+	HasVideoEncoder bool
+	HasAudioEncoder bool
+
+	// HasBitrates is set if VideoKbps and AudioKbps could be worked out.
+	HasBitrates bool
+	VideoKbps   float64
+	AudioKbps   float64
+
+	DynamicBitrate bool
+	// HasCurrentBitrate is set if the video encoder has a bitrate setting.
+	HasCurrentBitrate bool
+	CurrentKbps       float64
+}
+
+type encoderSnapshot struct {
+	ID          string
+	Name        string
+	DisplayName string
+	Codec       string
+
+	Active     bool
+	IsAudio    bool
+	Width      float64
+	Height     float64
+	SampleRate float64
+	// HasOutputDrops is set for video encoders feeding at least one output, in which case
+	// OutputDroppedFrames is the total frames those outputs have dropped.
+	HasOutputDrops      bool
+	OutputDroppedFrames float64
+	// Reconfigurable is set if the encoder can change its bitrate while it's running.
+	Reconfigurable bool
+	// Track is the audio track an audio encoder encodes, starting from 1.
+	Track int
+
+	// HasFPSDivisor is set for video encoders if FPSDivisor could be worked out.
+	HasFPSDivisor bool
+	FPSDivisor    int
+	Settings      encoderSettings
+
+	// HasCPUPercent is set if CPUPercent could be estimated.
+	HasCPUPercent bool
+	CPUPercent    float64
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <obs.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+)
+
+// sourceLabelData is what's available to OBS_EXPORTER_SOURCE_NAME_TEMPLATE.
+type sourceLabelData struct {
+	// ID is the source's type ID, e.g. "wasapi_input_capture".
+	ID string
+	// Name is the user-visible source name.
+	Name string
+	// Type is one of "input", "filter", "transition" or "scene".
+	Type string
+}
+
+func sourceTypeName(t C.enum_obs_source_type) string {
+	switch t {
+	case C.OBS_SOURCE_TYPE_INPUT:
+		return "input"
+	case C.OBS_SOURCE_TYPE_FILTER:
+		return "filter"
+	case C.OBS_SOURCE_TYPE_TRANSITION:
+		return "transition"
+	case C.OBS_SOURCE_TYPE_SCENE:
+		return "scene"
+	}
+	return "unknown"
+}
+
+func compileSourceNameTemplate(text string) (*template.Template, error) {
+	return template.New("source_name").Option("missingkey=error").Parse(text)
+}
+
+// renderSourceName renders the source_name label for a source, falling back to the raw name if there's no template or it fails.
+func renderSourceName(tmpl *template.Template, d sourceLabelData) string {
+	if tmpl == nil {
+		return d.Name
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, d); err != nil {
+		slog.Debug("failed to render source name template", "source_id", d.ID, "source_name", d.Name, "err", err)
+		return d.Name
+	}
+	return b.String()
+}
+
+func sourceLabelName(o *C.obs_source_t, tmpl *template.Template) string {
+	return renderSourceName(tmpl, sourceLabelData{
+		ID:   C.GoString(C.obs_source_get_id(o)),
+		Name: C.GoString(C.obs_source_get_name(o)),
+		Type: sourceTypeName(C.obs_source_get_type(o)),
+	})
+}
+
+// uniqueSourceNames renames sources whose source_name is the same as an earlier source's, by
+// appending their UUID. Otherwise their series would have the same labels and the whole scrape
+// would fail, which a template that leaves out the name, or falls back to it, makes easy.
+func uniqueSourceNames(snaps []sourceSnapshot) {
+	seen := map[string]bool{}
+	for i := range snaps {
+		s := &snaps[i]
+		if seen[s.Name] {
+			s.Name = fmt.Sprintf("%s (%s)", s.Name, s.UUID)
+		}
+		seen[s.Name] = true
+	}
+}
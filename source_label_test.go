@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestRenderSourceName(t *testing.T) {
+	d := sourceLabelData{ID: "wasapi_input_capture", Name: "Mic", Type: "input"}
+	for _, tc := range []struct {
+		template string
+		want     string
+	}{
+		{"", "Mic"},
+		{"{{.Type}}/{{.Name}}", "input/Mic"},
+		{"{{.ID}}: {{.Name}}", "wasapi_input_capture: Mic"},
+		// Failing templates fall back to the raw name.
+		{"{{.Missing}}", "Mic"},
+	} {
+		var got string
+		if tc.template == "" {
+			got = renderSourceName(nil, d)
+		} else {
+			tmpl, err := compileSourceNameTemplate(tc.template)
+			if err != nil {
+				t.Fatalf("compileSourceNameTemplate(%q): %v", tc.template, err)
+			}
+			got = renderSourceName(tmpl, d)
+		}
+		if got != tc.want {
+			t.Errorf("renderSourceName(%q) = %q, want %q", tc.template, got, tc.want)
+		}
+	}
+
+	if _, err := compileSourceNameTemplate("{{.Name"); err == nil {
+		t.Error("compileSourceNameTemplate of an unterminated action succeeded, want an error")
+	}
+}
+
+func TestUniqueSourceNames(t *testing.T) {
+	snaps := []sourceSnapshot{
+		{UUID: "a", Name: "input"},
+		{UUID: "b", Name: "Mic"},
+		{UUID: "c", Name: "input"},
+		{UUID: "d", Name: "input"},
+	}
+	uniqueSourceNames(snaps)
+	want := []string{"input", "Mic", "input (c)", "input (d)"}
+	for i, s := range snaps {
+		if s.Name != want[i] {
+			t.Errorf("source %s named %q, want %q", s.UUID, s.Name, want[i])
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <stdlib.h>
+#include <obs.h>
+
+void mc_source_volume_cb(void*, calldata_t*);
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+var signalVolume = C.CString("volume")
+
+// connectSignals hooks up the signal handlers for a newly tracked source.
+// We only keep a weak reference, so that we don't keep the source alive.
+func (s *Source) connectSignals(o *C.obs_source_t) {
+	s.Weak = C.obs_source_get_weak_source(o)
+	sh := C.obs_source_get_signal_handler(o)
+	C.signal_handler_connect(sh, signalVolume, C.signal_callback_t(C.mc_source_volume_cb), unsafe.Pointer(s.CID))
+}
+
+// disconnectSignals undoes connectSignals. If the source has already been destroyed, so have its signal handlers.
+func (s *Source) disconnectSignals() {
+	if s.Weak == nil {
+		return
+	}
+	if o := C.obs_weak_source_get_source(s.Weak); o != nil {
+		sh := C.obs_source_get_signal_handler(o)
+		C.signal_handler_disconnect(sh, signalVolume, C.signal_callback_t(C.mc_source_volume_cb), unsafe.Pointer(s.CID))
+		C.obs_source_release(o)
+	}
+	C.obs_weak_source_release(s.Weak)
+	s.Weak = nil
+}
+
+//export mc_source_volume_cb_go
+func mc_source_volume_cb_go(f unsafe.Pointer) {
+	activeMetricCollector.volumeChanged(C.GoString((*C.char)(f)))
+}
+
+// volumeChanged counts a volume signal from a source. Signals can still arrive from a source while
+// it's being removed, and they're ignored once it is.
+func (c *MetricCollector) volumeChanged(uuid string) {
+	c.mu.Lock()
+	src, ok := c.sources[uuid]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	src.mu.Lock()
+	src.VolumeChanges++
+	src.mu.Unlock()
+}
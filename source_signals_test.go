@@ -0,0 +1,40 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestVolumeChanged(t *testing.T) {
+	c := newTestCollector(t)
+	src := &Source{ID: "wasapi_input_capture", UUID: "uuid-1", Name: "Mic"}
+	c.sources[src.UUID] = src
+
+	c.volumeChanged(src.UUID)
+	c.volumeChanged(src.UUID)
+	if src.VolumeChanges != 2 {
+		t.Errorf("VolumeChanges = %d after two signals, want 2", src.VolumeChanges)
+	}
+	if got := src.snapshotMeter(defaultConfig()).VolumeChanges; got != 2 {
+		t.Errorf("snapshot VolumeChanges = %d, want 2", got)
+	}
+
+	// A signal that was already on its way when the source was removed.
+	delete(c.sources, src.UUID)
+	c.volumeChanged(src.UUID)
+	if src.VolumeChanges != 2 {
+		t.Errorf("VolumeChanges = %d after a signal from a removed source, want 2", src.VolumeChanges)
+	}
+	c.volumeChanged("never-seen")
+}
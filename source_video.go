@@ -0,0 +1,25 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// sourceFrozen reports whether a video source looks like it's stopped producing frames.
+// A capture source whose target has gone away reports a size of 0x0, but that's also normal
+// for sources that aren't being shown, so only sources that are being shown count.
+//
+// libobs doesn't give plugins a per-source frame counter, so a source that's stuck
+// showing its last frame at the right size can't be detected here.
+func sourceFrozen(showing bool, width, height uint32) bool {
+	return showing && (width == 0 || height == 0)
+}
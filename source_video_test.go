@@ -0,0 +1,38 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestSourceFrozen(t *testing.T) {
+	for _, tc := range []struct {
+		showing       bool
+		width, height uint32
+		want          bool
+	}{
+		{showing: true, width: 1920, height: 1080, want: false},
+		{showing: true, width: 0, height: 0, want: true},
+		{showing: true, width: 1920, height: 0, want: true},
+		// Hidden sources often have no size.
+		{showing: false, width: 0, height: 0, want: false},
+		{showing: false, width: 1920, height: 1080, want: false},
+	} {
+		if got := sourceFrozen(tc.showing, tc.width, tc.height); got != tc.want {
+			t.Errorf("sourceFrozen(%v, %d, %d) = %v, want %v", tc.showing, tc.width, tc.height, got, tc.want)
+		}
+	}
+}
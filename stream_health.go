@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// healthWeights is how much each component counts towards obs_stream_health_score.
+// Every component is a fraction from 0 (healthy) to 1 (as bad as it gets).
+type healthWeights struct {
+	// Congestion is the highest congestion of any active streaming output.
+	Congestion float64 `json:"congestion"`
+	// DroppedFrames is the highest ratio of dropped to total frames of any active streaming output.
+	DroppedFrames float64 `json:"dropped_frames"`
+	// RenderLag and EncodeLag are the fractions of frames missed due to rendering and encoding lag.
+	RenderLag float64 `json:"render_lag"`
+	EncodeLag float64 `json:"encode_lag"`
+}
+
+var defaultHealthWeights = healthWeights{
+	Congestion:    0.4,
+	DroppedFrames: 0.3,
+	RenderLag:     0.15,
+	EncodeLag:     0.15,
+}
+
+// parseHealthWeights reads weights from a JSON object. Components that aren't listed keep their default weight.
+func parseHealthWeights(s string) (healthWeights, error) {
+	w := defaultHealthWeights
+	if err := json.Unmarshal([]byte(s), &w); err != nil {
+		return healthWeights{}, err
+	}
+	if w.Congestion < 0 || w.DroppedFrames < 0 || w.RenderLag < 0 || w.EncodeLag < 0 {
+		return healthWeights{}, fmt.Errorf("weights can't be negative: %+v", w)
+	}
+	if w.total() == 0 {
+		return healthWeights{}, errors.New("at least one weight must be positive")
+	}
+	return w, nil
+}
+
+func (w healthWeights) total() float64 {
+	return w.Congestion + w.DroppedFrames + w.RenderLag + w.EncodeLag
+}
+
+// clampUnit clamps v to [0, 1].
+func clampUnit(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// streamHealthScore combines the components into a score from 0 (unwatchable) to 100 (perfect),
+// by taking the weighted average of the components, clamped to [0, 1], away from 100.
+func streamHealthScore(congestion, droppedFrames, renderLag, encodeLag float64, w healthWeights) float64 {
+	total := w.total()
+	if total <= 0 {
+		return 100
+	}
+	bad := w.Congestion*clampUnit(congestion) +
+		w.DroppedFrames*clampUnit(droppedFrames) +
+		w.RenderLag*clampUnit(renderLag) +
+		w.EncodeLag*clampUnit(encodeLag)
+	return 100 * (1 - clampUnit(bad/total))
+}
+
+// snapshotHealthScore works out obs_stream_health_score from the same snapshot as the metrics it's made of.
+func snapshotHealthScore(snap *collectorSnapshot, w healthWeights) float64 {
+	var congestion, dropped float64
+	for _, o := range snap.Outputs {
+		if !o.Active || o.Kind != outputKindStreaming {
+			continue
+		}
+		if o.Congestion > congestion {
+			congestion = o.Congestion
+		}
+		if ratio, ok := droppedFramesRatio(o.DroppedFrames, o.TotalFrames); ok && ratio > dropped {
+			dropped = ratio
+		}
+	}
+	g := snap.Global
+	renderLag := lagPercent(g.LaggedFrames, g.TotalFrames) / 100
+	encodeLag := lagPercent(g.VideoSkippedFrames, g.VideoTotalFrames) / 100
+	return streamHealthScore(congestion, dropped, renderLag, encodeLag, w)
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStreamHealthScore(t *testing.T) {
+	for _, tc := range []struct {
+		name                                            string
+		congestion, droppedFrames, renderLag, encodeLag float64
+		weights                                         healthWeights
+		want                                            float64
+	}{
+		{"healthy", 0, 0, 0, 0, defaultHealthWeights, 100},
+		{"mixed", 0.5, 0.1, 0, 0.2, defaultHealthWeights, 74},
+		{"only congestion counts", 0.25, 1, 1, 1, healthWeights{Congestion: 1}, 75},
+		{"unnormalised weights", 1, 0, 0, 0, healthWeights{Congestion: 2, DroppedFrames: 2}, 50},
+		// Components outside [0, 1] are clamped rather than pushing the score out of range.
+		{"clamped high", 5, 5, 5, 5, defaultHealthWeights, 0},
+		{"clamped low", -1, -1, -1, -1, defaultHealthWeights, 100},
+		{"partly clamped", 2, 0, 0, 0, defaultHealthWeights, 60},
+		{"no weights", 1, 1, 1, 1, healthWeights{}, 100},
+	} {
+		got := streamHealthScore(tc.congestion, tc.droppedFrames, tc.renderLag, tc.encodeLag, tc.weights)
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s: streamHealthScore = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestClampUnit(t *testing.T) {
+	for in, want := range map[float64]float64{-0.5: 0, 0: 0, 0.25: 0.25, 1: 1, 3: 1} {
+		if got := clampUnit(in); got != want {
+			t.Errorf("clampUnit(%v) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestSnapshotHealthScore(t *testing.T) {
+	snap := &collectorSnapshot{
+		Global: globalSnapshot{TotalFrames: 1000, LaggedFrames: 100},
+		Outputs: []outputSnapshot{
+			{Kind: outputKindStreaming, Active: true, Congestion: 0.5, TotalFrames: 1000, DroppedFrames: 100},
+			// Recordings and inactive streams don't affect stream health.
+			{Kind: outputKindRecording, Active: true, Congestion: 1, TotalFrames: 1000, DroppedFrames: 1000},
+			{Kind: outputKindStreaming, Congestion: 1, TotalFrames: 1000, DroppedFrames: 1000},
+		},
+	}
+	// 100 * (1 - (0.4*0.5 + 0.3*0.1 + 0.15*0.1 + 0.15*0)) = 75.5
+	if got := snapshotHealthScore(snap, defaultHealthWeights); math.Abs(got-75.5) > 1e-9 {
+		t.Errorf("snapshotHealthScore = %v, want 75.5", got)
+	}
+}
+
+func TestParseHealthWeights(t *testing.T) {
+	w, err := parseHealthWeights(`{"congestion": 1, "encode_lag": 0}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := defaultHealthWeights
+	want.Congestion, want.EncodeLag = 1, 0
+	if w != want {
+		t.Errorf("parseHealthWeights = %+v, want %+v", w, want)
+	}
+	for _, s := range []string{
+		`not json`,
+		`{"render_lag": -1}`,
+		`{"congestion": 0, "dropped_frames": 0, "render_lag": 0, "encode_lag": 0}`,
+	} {
+		if _, err := parseHealthWeights(s); err == nil {
+			t.Errorf("parseHealthWeights(%q) succeeded, want an error", s)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// testCert is a certificate and key written to PEM files for a test.
+type testCert struct {
+	Cert     *x509.Certificate
+	Key      *ecdsa.PrivateKey
+	CertFile string
+	KeyFile  string
+}
+
+// newTestCert creates a certificate valid until notAfter, signed by parent, or self-signed if parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert, isCA bool, notAfter time.Time) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.Cert, parent.Key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	tc := &testCert{Cert: cert, Key: key, CertFile: filepath.Join(dir, name+".crt"), KeyFile: filepath.Join(dir, name+".key")}
+	if err := os.WriteFile(tc.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tc.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return tc
+}
+
+func TestMutualTLSRejectsClientsWithoutCert(t *testing.T) {
+	expiry := time.Now().Add(24 * time.Hour)
+	ca := newTestCert(t, "ca", nil, true, expiry)
+	server := newTestCert(t, "server", ca, false, expiry)
+	client := newTestCert(t, "client", ca, false, expiry)
+
+	cfg, err := loadTLSConfig(server.CertFile, server.KeyFile, ca.CertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	get := func(certs []tls.Certificate) error {
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+		resp, err := c.Get(srv.URL)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	if err := get(nil); err == nil {
+		t.Error("request without a client certificate succeeded")
+	}
+	clientPair, err := tls.LoadX509KeyPair(client.CertFile, client.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := get([]tls.Certificate{clientPair}); err != nil {
+		t.Errorf("request with a client certificate signed by the CA failed: %v", err)
+	}
+}
+
+func TestCertExpiry(t *testing.T) {
+	want := time.Now().Add(90 * 24 * time.Hour).Truncate(time.Second)
+	ca := newTestCert(t, "ca", nil, true, want.Add(time.Hour))
+	server := newTestCert(t, "server", ca, false, want)
+	// The chain has the CA in it too, which expires later; it's the leaf we want.
+	chain, err := os.ReadFile(server.CertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Cert.Raw})...)
+	if err := os.WriteFile(server.CertFile, chain, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.LoadX509KeyPair(server.CertFile, server.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := certExpiry(cert)
+	if err != nil {
+		t.Fatalf("certExpiry: %v", err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("certExpiry = %v, want %v", got, want)
+	}
+
+	if _, err := loadTLSConfig(server.CertFile, server.KeyFile, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(tlsCertExpiry.WithLabelValues(server.CertFile)); got != float64(want.Unix()) {
+		t.Errorf("obs_exporter_tls_cert_expiry_timestamp_seconds = %v, want %v", got, want.Unix())
+	}
+
+	if _, err := certExpiry(tls.Certificate{}); err == nil {
+		t.Error("certExpiry of an empty certificate succeeded")
+	}
+}
+
+func TestShutdownServersResetsTLSMetrics(t *testing.T) {
+	expiry := time.Now().Add(24 * time.Hour)
+	server := newTestCert(t, "server", nil, false, expiry)
+	cfg, err := loadTLSConfig(server.CertFile, server.KeyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveListener(ln, cfg, http.NotFoundHandler())
+	if got := testutil.ToFloat64(tlsEnabled); got != 1 {
+		t.Fatalf("obs_exporter_tls_enabled = %v while serving HTTPS, want 1", got)
+	}
+
+	shutdownServers(time.Second)
+	if got := testutil.ToFloat64(tlsEnabled); got != 0 {
+		t.Errorf("obs_exporter_tls_enabled = %v after shutting down, want 0", got)
+	}
+	if n := testutil.CollectAndCount(tlsCertExpiry); n != 0 {
+		t.Errorf("obs_exporter_tls_cert_expiry_timestamp_seconds has %d series after shutting down, want 0", n)
+	}
+}
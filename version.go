@@ -0,0 +1,71 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <obs.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The range of libobs API versions, packed as by MAKE_SEMANTIC_VERSION, that the exporter is known to work with.
+const (
+	minSupportedAPIVersion = 28 << 24
+	// maxSupportedAPIVersion is exclusive.
+	maxSupportedAPIVersion = 31 << 24
+)
+
+var apiSupported = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: exporterSubsystem,
+	Name:      "api_supported",
+	Help:      "Whether the running libobs API version is one the exporter is known to work with.",
+})
+
+// exporterVersion is the version of the exporter, reported by obs_exporter_build_info. Release
+// builds set it with -ldflags "-X main.exporterVersion=...".
+var exporterVersion = "dev"
+
+// builtAgainstAPIVersion is the LIBOBS_API_VER the exporter was compiled against.
+var builtAgainstAPIVersion = formatAPIVersion(uint32(C.LIBOBS_API_VER))
+
+func formatAPIVersion(v uint32) string {
+	return fmt.Sprintf("%d.%d.%d", v>>24, (v>>16)&0xff, v&0xffff)
+}
+
+func apiVersionSupported(v uint32) bool {
+	return v >= minSupportedAPIVersion && v < maxSupportedAPIVersion
+}
+
+// checkAPIVersion compares the version of libobs we're running against, which may differ from
+// LIBOBS_API_VER if OBS has been upgraded since the exporter was built, with the supported range.
+func checkAPIVersion() {
+	v := uint32(C.obs_get_version())
+	if !apiVersionSupported(v) {
+		slog.Warn("running on an unsupported version of OBS, some metrics may be missing or wrong",
+			"version", formatAPIVersion(v), "built_against", formatAPIVersion(uint32(C.LIBOBS_API_VER)),
+			"supported", fmt.Sprintf(">=%s, <%s", formatAPIVersion(minSupportedAPIVersion), formatAPIVersion(maxSupportedAPIVersion)))
+		apiSupported.Set(0)
+		return
+	}
+	apiSupported.Set(1)
+}
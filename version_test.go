@@ -0,0 +1,47 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+// apiVersion packs a version like MAKE_SEMANTIC_VERSION does.
+func apiVersion(major, minor, patch uint32) uint32 {
+	return major<<24 | minor<<16 | patch
+}
+
+func TestAPIVersionSupported(t *testing.T) {
+	for _, tc := range []struct {
+		version uint32
+		want    bool
+	}{
+		{apiVersion(27, 2, 4), false},
+		{apiVersion(28, 0, 0), true},
+		{apiVersion(29, 1, 3), true},
+		{apiVersion(30, 2, 3), true},
+		{apiVersion(30, 255, 65535), true},
+		{apiVersion(31, 0, 0), false},
+		{apiVersion(32, 1, 0), false},
+	} {
+		if got := apiVersionSupported(tc.version); got != tc.want {
+			t.Errorf("apiVersionSupported(%s) = %v, want %v", formatAPIVersion(tc.version), got, tc.want)
+		}
+	}
+}
+
+func TestFormatAPIVersion(t *testing.T) {
+	if got, want := formatAPIVersion(apiVersion(30, 1, 2)), "30.1.2"; got != want {
+		t.Errorf("formatAPIVersion = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <obs.h>
+#include <media-io/video-io.h>
+*/
+import "C"
+
+import (
+	"math"
+)
+
+// obsVideoInfo is libobs's struct obs_video_info.
+type obsVideoInfo = C.struct_obs_video_info
+
+type canvasSnapshot struct {
+	BaseWidth  uint32
+	BaseHeight uint32
+	Colorspace string
+	Range      string
+}
+
+// colorspaceName returns the name OBS's advanced video settings store a color space as.
+func colorspaceName(cs C.enum_video_colorspace) string {
+	switch cs {
+	case C.VIDEO_CS_DEFAULT:
+		return "default"
+	case C.VIDEO_CS_601:
+		return "601"
+	case C.VIDEO_CS_709:
+		return "709"
+	case C.VIDEO_CS_SRGB:
+		return "sRGB"
+	case C.VIDEO_CS_2100_PQ:
+		return "2100PQ"
+	case C.VIDEO_CS_2100_HLG:
+		return "2100HLG"
+	}
+	return "unknown"
+}
+
+func videoRangeName(r C.enum_video_range_type) string {
+	switch r {
+	case C.VIDEO_RANGE_DEFAULT:
+		return "default"
+	case C.VIDEO_RANGE_PARTIAL:
+		return "partial"
+	case C.VIDEO_RANGE_FULL:
+		return "full"
+	}
+	return "unknown"
+}
+
+// scaleTypeName returns the name OBS's video settings use for a downscale filter.
+func scaleTypeName(t C.enum_obs_scale_type) string {
+	switch t {
+	case C.OBS_SCALE_DISABLE:
+		return "disable"
+	case C.OBS_SCALE_POINT:
+		return "point"
+	case C.OBS_SCALE_BICUBIC:
+		return "bicubic"
+	case C.OBS_SCALE_BILINEAR:
+		return "bilinear"
+	case C.OBS_SCALE_LANCZOS:
+		return "lanczos"
+	case C.OBS_SCALE_AREA:
+		return "area"
+	}
+	return "unknown"
+}
+
+// outputRescale returns the scale filter used for an output whose size differs from the
+// canvas's output size. It returns false if the output isn't rescaling.
+func outputRescale(width, height uint32) (string, bool) {
+	var ovi obsVideoInfo
+	if !C.obs_get_video_info(&ovi) {
+		return "", false
+	}
+	return rescaleFilter(ovi, width, height)
+}
+
+// rescaleFilter is outputRescale for the video set up by ovi.
+func rescaleFilter(ovi obsVideoInfo, width, height uint32) (string, bool) {
+	if width == 0 || height == 0 {
+		return "", false
+	}
+	if width == uint32(ovi.output_width) && height == uint32(ovi.output_height) {
+		return "", false
+	}
+	return scaleTypeName(ovi.scale_type), true
+}
+
+// snapshotCanvas returns the canvas OBS renders scenes to. It returns false if video isn't set up.
+func snapshotCanvas() (canvasSnapshot, bool) {
+	var ovi obsVideoInfo
+	if !C.obs_get_video_info(&ovi) {
+		return canvasSnapshot{}, false
+	}
+	return canvasFromVideoInfo(ovi), true
+}
+
+// canvasFromVideoInfo describes the canvas set up by ovi.
+func canvasFromVideoInfo(ovi obsVideoInfo) canvasSnapshot {
+	return canvasSnapshot{
+		BaseWidth:  uint32(ovi.base_width),
+		BaseHeight: uint32(ovi.base_height),
+		Colorspace: colorspaceName(ovi.colorspace),
+		Range:      videoRangeName(ovi._range),
+	}
+}
+
+// targetFPS returns the configured framerate, or 0 if video isn't set up.
+func targetFPS() float64 {
+	var ovi C.struct_obs_video_info
+	if !C.obs_get_video_info(&ovi) || ovi.fps_den == 0 {
+		return 0
+	}
+	return float64(ovi.fps_num) / float64(ovi.fps_den)
+}
+
+// fpsRatio returns how close active is to target, clamped to [0, 1].
+// It returns false if there's no target to compare against.
+func fpsRatio(active, target float64) (float64, bool) {
+	if target <= 0 {
+		return 0, false
+	}
+	ratio := active / target
+	if ratio < 0 {
+		return 0, true
+	}
+	if ratio > 1 {
+		return 1, true
+	}
+	return ratio, true
+}
+
+// encoderFPS returns the framerate a video encoder is encoding at, or 0 if it has no video.
+func encoderFPS(e *C.obs_encoder_t) float64 {
+	video := C.obs_encoder_video(e)
+	if video == nil {
+		return 0
+	}
+	return float64(C.video_output_get_frame_rate(video))
+}
+
+// fpsDivisor returns how many base frames there are for each frame an encoder encodes.
+// It returns false if either framerate isn't known.
+func fpsDivisor(base, encoder float64) (int, bool) {
+	if base <= 0 || encoder <= 0 {
+		return 0, false
+	}
+	d := int(math.Round(base / encoder))
+	if d < 1 {
+		d = 1
+	}
+	return d, true
+}
+
+// lagPercent works out the percentages shown in OBS's stats dock: frames missed
+// due to rendering lag, and frames skipped due to encoding lag.
+func lagPercent(missed, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return missed / total * 100
+}
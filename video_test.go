@@ -0,0 +1,152 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestFPSRatio(t *testing.T) {
+	for _, tc := range []struct {
+		active, target float64
+		want           float64
+		wantOK         bool
+	}{
+		{60, 60, 1, true},
+		{30, 60, 0.5, true},
+		{59.94, 60, 0.999, true},
+		// The active FPS can briefly read above the target.
+		{61, 60, 1, true},
+		{0, 60, 0, true},
+		{60, 0, 0, false},
+	} {
+		got, ok := fpsRatio(tc.active, tc.target)
+		if ok != tc.wantOK || (got-tc.want > 1e-9 || tc.want-got > 1e-9) {
+			t.Errorf("fpsRatio(%v, %v) = %v, %v, want %v, %v", tc.active, tc.target, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestFPSDivisor(t *testing.T) {
+	for _, tc := range []struct {
+		base, encoder float64
+		want          int
+		wantOK        bool
+	}{
+		{60, 60, 1, true},
+		{60, 30, 2, true},
+		{59.94, 29.97, 2, true},
+		{60, 20, 3, true},
+		// Rounding shouldn't give a divisor below 1 for an encoder that reads slightly fast.
+		{60, 61, 1, true},
+		{0, 30, 0, false},
+		{60, 0, 0, false},
+	} {
+		if got, ok := fpsDivisor(tc.base, tc.encoder); got != tc.want || ok != tc.wantOK {
+			t.Errorf("fpsDivisor(%v, %v) = %d, %v, want %d, %v", tc.base, tc.encoder, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestLagPercent(t *testing.T) {
+	for _, tc := range []struct {
+		missed, total float64
+		want          float64
+	}{
+		// The stats dock shows 12 of 3600 frames missed due to rendering lag as 0.3%.
+		{12, 3600, 1.0 / 3},
+		{0, 3600, 0},
+		{3600, 3600, 100},
+		// Nothing has been rendered or encoded yet.
+		{0, 0, 0},
+	} {
+		if got := lagPercent(tc.missed, tc.total); got-tc.want > 1e-9 || tc.want-got > 1e-9 {
+			t.Errorf("lagPercent(%v, %v) = %v, want %v", tc.missed, tc.total, got, tc.want)
+		}
+	}
+}
+
+func TestCanvasFromVideoInfo(t *testing.T) {
+	for _, tc := range []struct {
+		ovi  obsVideoInfo
+		want canvasSnapshot
+	}{
+		// VIDEO_CS_709 and VIDEO_RANGE_PARTIAL, OBS's defaults.
+		{obsVideoInfo{base_width: 1920, base_height: 1080, colorspace: 2, _range: 1},
+			canvasSnapshot{BaseWidth: 1920, BaseHeight: 1080, Colorspace: "709", Range: "partial"}},
+		// VIDEO_CS_2100_PQ and VIDEO_RANGE_FULL, for HDR.
+		{obsVideoInfo{base_width: 3840, base_height: 2160, colorspace: 4, _range: 2},
+			canvasSnapshot{BaseWidth: 3840, BaseHeight: 2160, Colorspace: "2100PQ", Range: "full"}},
+		// Values from a newer libobs than we know about.
+		{obsVideoInfo{base_width: 1280, base_height: 720, colorspace: 99, _range: 99},
+			canvasSnapshot{BaseWidth: 1280, BaseHeight: 720, Colorspace: "unknown", Range: "unknown"}},
+	} {
+		if got := canvasFromVideoInfo(tc.ovi); got != tc.want {
+			t.Errorf("canvasFromVideoInfo = %+v, want %+v", got, tc.want)
+		}
+	}
+}
+
+func TestEmitColorspaceInfo(t *testing.T) {
+	snap := &collectorSnapshot{Up: true}
+	snap.Global.HasCanvas = true
+	snap.Global.Canvas = canvasSnapshot{BaseWidth: 1920, BaseHeight: 1080, Colorspace: "709", Range: "partial"}
+	snap.Global.SDRWhiteNits = 300
+	ms := emitSnapshot(t, newTestCollector(t), snap)
+
+	want := map[string]string{"colorspace": "709", "range": "partial", "sdr_white_nits": "300"}
+	if _, ok := findMetric(ms, "obs_video_colorspace_info", want); !ok {
+		t.Errorf("no obs_video_colorspace_info with labels %v", want)
+	}
+}
+
+func TestEmitOutputVideoSkippedFrames(t *testing.T) {
+	snap := &collectorSnapshot{Up: true, Outputs: []outputSnapshot{
+		{ID: "rtmp_output", Name: "simple_stream", HasVideo: true, VideoSkippedFrames: 42},
+		// An output without a video pipeline, like an audio-only recording.
+		{ID: "flac_output", Name: "audio_only"},
+	}}
+	ms := emitSnapshot(t, newTestCollector(t), snap)
+
+	if m, ok := findMetric(ms, "obs_output_video_skipped_frames_total", map[string]string{"output_name": "simple_stream"}); !ok || m.Value != 42 {
+		t.Errorf("obs_output_video_skipped_frames_total = %v (emitted %v), want 42", m.Value, ok)
+	}
+	if _, ok := findMetric(ms, "obs_output_video_skipped_frames_total", map[string]string{"output_name": "audio_only"}); ok {
+		t.Error("obs_output_video_skipped_frames_total emitted for an output without video")
+	}
+}
+
+func TestRescaleFilter(t *testing.T) {
+	ovi := obsVideoInfo{output_width: 1920, output_height: 1080}
+	for _, tc := range []struct {
+		scaleType     uint32
+		width, height uint32
+		want          string
+		wantOK        bool
+	}{
+		// OBS_SCALE_BICUBIC, OBS_SCALE_BILINEAR and OBS_SCALE_LANCZOS.
+		{2, 1280, 720, "bicubic", true},
+		{3, 1280, 720, "bilinear", true},
+		{4, 1920, 1200, "lanczos", true},
+		// OBS_SCALE_AREA.
+		{5, 960, 540, "area", true},
+		{2, 1920, 1080, "", false},
+		// Outputs that haven't started yet don't have a size.
+		{2, 0, 0, "", false},
+	} {
+		ovi.scale_type = tc.scaleType
+		if got, ok := rescaleFilter(ovi, tc.width, tc.height); got != tc.want || ok != tc.wantOK {
+			t.Errorf("rescaleFilter(scale type %d, %dx%d) = %q, %v, want %q, %v", tc.scaleType, tc.width, tc.height, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+/*
+#cgo CFLAGS: -Ithird_party/obs-studio/libobs
+#include <stdlib.h>
+#include <obs-module.h>
+#include <obs.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"unsafe"
+)
+
+const (
+	websocketModuleName = "obs-websocket"
+	// obs-websocket 5.3+ keeps its settings in plugin_config/obs-websocket/config.json.
+	websocketConfigFile = "config.json"
+)
+
+// websocketConfigs caches obs-websocket's config file, which is read on every scrape.
+var websocketConfigs fileCache
+
+// websocketServerEnabled reports whether obs-websocket's config file turns its server on.
+func websocketServerEnabled(data []byte) (interface{}, error) {
+	var cfg struct {
+		ServerEnabled bool `json:"server_enabled"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return false, err
+	}
+	return cfg.ServerEnabled, nil
+}
+
+// websocketState reports whether obs-websocket is loaded and, if so, whether its server is enabled.
+//
+// obs-websocket doesn't expose its connected clients to other plugins, so this is as much as we can find out.
+func websocketState() (loaded, enabled bool) {
+	nameC := C.CString(websocketModuleName)
+	defer C.free(unsafe.Pointer(nameC))
+	mod := C.obs_get_module(nameC)
+	if mod == nil {
+		return false, false
+	}
+
+	fileC := C.CString(websocketConfigFile)
+	defer C.free(unsafe.Pointer(fileC))
+	path := C.obs_module_get_config_path(mod, fileC)
+	if path == nil {
+		return true, false
+	}
+	defer C.bfree(unsafe.Pointer(path))
+
+	v, err := websocketConfigs.get(C.GoString(path), websocketServerEnabled)
+	if err != nil {
+		return true, false
+	}
+	return true, v.(bool)
+}
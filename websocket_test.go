@@ -0,0 +1,87 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWebsocketServerEnabled(t *testing.T) {
+	for _, tc := range []struct {
+		config  string
+		want    bool
+		wantErr bool
+	}{
+		{`{"server_enabled": true, "server_port": 4455}`, true, false},
+		{`{"server_enabled": false}`, false, false},
+		{`{"server_port": 4455}`, false, false},
+		{`not json`, false, true},
+	} {
+		got, err := websocketServerEnabled([]byte(tc.config))
+		if (err != nil) != tc.wantErr {
+			t.Errorf("websocketServerEnabled(%q) error = %v, want error %v", tc.config, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("websocketServerEnabled(%q) = %v, want %v", tc.config, got, tc.want)
+		}
+	}
+}
+
+func TestFileCacheRereadsChangedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	write := func(content string, mtime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var fc fileCache
+	reads := 0
+	parse := func(data []byte) (interface{}, error) {
+		reads++
+		return websocketServerEnabled(data)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	write(`{"server_enabled": true}`, start)
+	for i := 0; i < 3; i++ {
+		if v, err := fc.get(path, parse); err != nil || v != true {
+			t.Fatalf("get = %v, %v, want true", v, err)
+		}
+	}
+	if reads != 1 {
+		t.Errorf("read an unchanged file %d times, want 1", reads)
+	}
+
+	write(`{"server_enabled": false}`, start.Add(time.Minute))
+	if v, err := fc.get(path, parse); err != nil || v != false {
+		t.Fatalf("get after changing the file = %v, %v, want false", v, err)
+	}
+	if reads != 2 {
+		t.Errorf("read the file %d times after it changed, want 2", reads)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fc.get(path, parse); err == nil {
+		t.Error("get of a removed file succeeded")
+	}
+}